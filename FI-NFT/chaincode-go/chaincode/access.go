@@ -0,0 +1,179 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const AccessGrantPrefix = "tokenID~licensee~grant"
+
+// AccessGrant is a license for Licensee to fetch the content of TokenID with Request until ExpireTime,
+// 0 meaning until revoked. It lapses when Grantor no longer owns the token, or holds none of its editions.
+type AccessGrant struct {
+	TokenID    string
+	Licensee   string
+	Grantor    string
+	GrantTime  uint64
+	ExpireTime uint64
+}
+
+// GrantAccess licenses licensee to fetch the content of tokenID for durationMinute minutes, or until
+// revoked for a zero durationMinute, replacing any earlier grant. The caller must own the token, or
+// hold an edition of a token class: an account approved for the token cannot license its content.
+// The license only gates the Request proxy: the CID itself is public, returned by GetNFTByID and
+// GetNFTByCID and raised in NFTMinted, so anyone can fetch the content from IPFS directly.
+func (s *SmartContract) GrantAccess(ctx contractapi.TransactionContextInterface, tokenID string, licensee string, durationMinute uint64) (*AccessGrant, error) {
+	grantor, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client id: %v", err)
+	}
+	if licensee == "" || licensee == grantor {
+		return nil, fmt.Errorf("failed to GrantAccess, invalid licensee\n")
+	}
+	nft, err := getNFT(ctx, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getNFT for GrantAccess: %v\n", err)
+	}
+	owner, err := ownsContent(ctx, nft, grantor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check owner for GrantAccess: %v\n", err)
+	}
+	if !owner {
+		return nil, fmt.Errorf("failed to GrantAccess, caller is not owner\n")
+	}
+	grantTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getTxTime for GrantAccess: %v\n", err)
+	}
+	grant := &AccessGrant{TokenID: tokenID, Licensee: licensee, Grantor: grantor, GrantTime: grantTime}
+	if durationMinute > 0 {
+		grant.ExpireTime = grantTime + durationMinute*60*1000
+	}
+	err = putAccessGrant(ctx, grant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to PutState for GrantAccess: %v\n", err)
+	}
+	err = emitEvent(ctx, AccessGrantedEvent, grant)
+	if err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// RevokeAccess ends the license of licensee on tokenID, with the same permission as GrantAccess.
+func (s *SmartContract) RevokeAccess(ctx contractapi.TransactionContextInterface, tokenID string, licensee string) error {
+	operator, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+	nft, err := getNFT(ctx, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to getNFT for RevokeAccess: %v\n", err)
+	}
+	owner, err := ownsContent(ctx, nft, operator)
+	if err != nil {
+		return fmt.Errorf("failed to check owner for RevokeAccess: %v\n", err)
+	}
+	if !owner {
+		return fmt.Errorf("failed to RevokeAccess, caller is not owner\n")
+	}
+	grant, err := getAccessGrant(ctx, tokenID, licensee)
+	if err != nil {
+		return fmt.Errorf("failed to getAccessGrant for RevokeAccess: %v\n", err)
+	}
+	if grant == nil {
+		return fmt.Errorf("failed to RevokeAccess, %s has no license\n", licensee)
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(AccessGrantPrefix, []string{tokenID, licensee})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return fmt.Errorf("failed to DelState for RevokeAccess: %v\n", err)
+	}
+	return emitEvent(ctx, AccessRevokedEvent, grant)
+}
+
+// GetAccessGrant returns the license of licensee on tokenID, expired or lapsed ones included.
+func (s *SmartContract) GetAccessGrant(ctx contractapi.TransactionContextInterface, tokenID string, licensee string) (*AccessGrant, error) {
+	grant, err := getAccessGrant(ctx, tokenID, licensee)
+	if err != nil {
+		return nil, err
+	}
+	if grant == nil {
+		return nil, fmt.Errorf("%s has no license on %s\n", licensee, tokenID)
+	}
+	return grant, nil
+}
+
+// canAccess reports whether account may fetch the content of nft: it owns it, or holds a license that
+// has not expired nor lapsed.
+func canAccess(ctx contractapi.TransactionContextInterface, nft *NFT, account string) (bool, error) {
+	owner, err := ownsContent(ctx, nft, account)
+	if err != nil || owner {
+		return owner, err
+	}
+	grant, err := getAccessGrant(ctx, nft.ID, account)
+	if err != nil || grant == nil {
+		return false, err
+	}
+	currentTime, err := getTxTime(ctx)
+	if err != nil {
+		return false, err
+	}
+	if grant.ExpireTime != 0 && currentTime > grant.ExpireTime {
+		return false, nil
+	}
+	return ownsContent(ctx, nft, grant.Grantor)
+}
+
+// ownsContent reports whether account owns nft, or holds an edition of a token class. Approvals do not count.
+// Editions listed with AddEditionAuction are still held by their seller until the auction ends.
+func ownsContent(ctx contractapi.TransactionContextInterface, nft *NFT, account string) (bool, error) {
+	if nft.Supply == 0 {
+		return nft.Owner == account, nil
+	}
+	editions, err := getEditionBalance(ctx, nft.ID, account)
+	if err != nil {
+		return false, err
+	}
+	if editions.Amount > 0 {
+		return true, nil
+	}
+	return bidExists(ctx, editionLotID(nft.ID, account))
+}
+
+func getAccessGrant(ctx contractapi.TransactionContextInterface, tokenID string, licensee string) (*AccessGrant, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(AccessGrantPrefix, []string{tokenID, licensee})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+	}
+	if len(jvalue) == 0 {
+		return nil, nil
+	}
+	grant := &AccessGrant{}
+	err = json.Unmarshal(jvalue, grant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data %v", err)
+	}
+	return grant, nil
+}
+
+func putAccessGrant(ctx contractapi.TransactionContextInterface, grant *AccessGrant) error {
+	key, err := ctx.GetStub().CreateCompositeKey(AccessGrantPrefix, []string{grant.TokenID, grant.Licensee})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := json.Marshal(grant)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data %v", err)
+	}
+	return ctx.GetStub().PutState(key, jvalue)
+}
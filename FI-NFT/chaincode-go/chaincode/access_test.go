@@ -0,0 +1,101 @@
+package chaincode
+
+import "testing"
+
+func (l *testLedger) expectAccess(tokenID string, account string, want bool) {
+	l.t.Helper()
+	ctx := l.ctx(account)
+	nft, err := getNFT(ctx, tokenID)
+	mustSucceed(l.t, err)
+	allowed, err := canAccess(ctx, nft, account)
+	mustSucceed(l.t, err)
+	if allowed != want {
+		l.t.Fatalf("access of %s to %s is %v, want %v", account, tokenID, allowed, want)
+	}
+}
+
+func TestRequestNeedsOwnerOrLicense(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.seedNFT("1", "owner")
+	_, err := s.Request(l.ctx("stranger"), "1")
+	mustFail(t, err, "operator is not the owner nor a licensee")
+	l.expectAccess("1", "owner", true)
+
+	_, err = s.GrantAccess(l.ctx("stranger"), "1", "licensee", 10)
+	mustFail(t, err, "caller is not owner")
+	_, err = s.GrantAccess(l.ctx("owner"), "1", "owner", 10)
+	mustFail(t, err, "invalid licensee")
+	grant, err := s.GrantAccess(l.ctx("owner"), "1", "licensee", 10)
+	mustSucceed(t, err)
+	if grant.Grantor != "owner" || grant.ExpireTime != l.nowMillis()+10*60*1000 {
+		t.Fatalf("grant is %+v", grant)
+	}
+	l.expectAccess("1", "licensee", true)
+	l.advance(11)
+	l.expectAccess("1", "licensee", false)
+	_, err = s.Request(l.ctx("licensee"), "1")
+	mustFail(t, err, "operator is not the owner nor a licensee")
+
+	// a zero duration lasts until revoked
+	_, err = s.GrantAccess(l.ctx("owner"), "1", "licensee", 0)
+	mustSucceed(t, err)
+	l.advance(60 * 24)
+	l.expectAccess("1", "licensee", true)
+	// an approved account can transfer the token but not fetch nor license its content
+	mustSucceed(t, s.Approve(l.ctx("owner"), "approved", "1"))
+	l.expectAccess("1", "approved", false)
+	_, err = s.GrantAccess(l.ctx("approved"), "1", "friend", 0)
+	mustFail(t, err, "caller is not owner")
+	mustFail(t, s.RevokeAccess(l.ctx("licensee"), "1", "licensee"), "caller is not owner")
+	mustSucceed(t, s.RevokeAccess(l.ctx("owner"), "1", "licensee"))
+	l.expectAccess("1", "licensee", false)
+	mustFail(t, s.RevokeAccess(l.ctx("owner"), "1", "licensee"), "licensee has no license")
+	_, err = s.GetAccessGrant(l.ctx("reader"), "1", "licensee")
+	mustFail(t, err, "licensee has no license on 1")
+}
+
+func TestLicenseLapsesWhenTheGrantorSells(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.seedNFT("1", "owner")
+	_, err := s.GrantAccess(l.ctx("owner"), "1", "licensee", 0)
+	mustSucceed(t, err)
+	mustSucceed(t, s.TransferNFT(l.ctx("owner"), "buyer", "1"))
+	l.expectAccess("1", "licensee", false)
+	l.expectAccess("1", "owner", false)
+	l.expectAccess("1", "buyer", true)
+	// the grant is kept, but only the new owner can renew it
+	_, err = s.GetAccessGrant(l.ctx("reader"), "1", "licensee")
+	mustSucceed(t, err)
+}
+
+func TestEditionHoldersKeepAccessWhileListed(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openTreasury()
+	l.openAccounts(1000, "creator", "holder", "buyer")
+	_, err := s.MintEditions(l.ctx("creator"), "class", testCID, "png", 100, 0, 10)
+	mustSucceed(t, err)
+	mustSucceed(t, s.TransferBatch(l.ctx("creator"), "creator", "holder", []string{"class"}, []uint64{2}))
+	l.expectAccess("class", "holder", true)
+	l.expectAccess("class", "buyer", false)
+	_, err = s.GrantAccess(l.ctx("holder"), "class", "licensee", 0)
+	mustSucceed(t, err)
+
+	// all the editions of holder are in the lot, which it still holds until the auction ends
+	bid, err := s.AddEditionAuction(l.ctx("holder"), "class", 2, AuctionFixedPrice, 0, 100, 10)
+	mustSucceed(t, err)
+	l.expectEditions([]string{"holder"}, []string{"class"}, 0)
+	l.expectAccess("class", "holder", true)
+	l.expectAccess("class", "licensee", true)
+	_, err = s.GrantAccess(l.ctx("holder"), "class", "friend", 0)
+	mustSucceed(t, err)
+
+	mustSucceed(t, s.Offer(l.ctx("buyer"), 100, bid.TokenID))
+	mustSucceed(t, s.TryEndBid(l.ctx("reader"), bid.TokenID))
+	l.expectAccess("class", "holder", false)
+	l.expectAccess("class", "licensee", false)
+	l.expectAccess("class", "buyer", true)
+	l.expectAccess("class", "creator", true)
+}
@@ -0,0 +1,219 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const AccountPrefix = "account~info"
+const BalanceAdjustmentPrefix = "account~timestamp~txID~adjustment"
+
+// AccountInfo is the registration record of an account, its balance stays in the AccountBalance record.
+type AccountInfo struct {
+	Account      string
+	MSPID        string
+	EnrollmentID string
+	CreateTime   uint64
+}
+
+// BalanceAdjustment is the audit record of one AdjustBalance.
+type BalanceAdjustment struct {
+	Account   string
+	Delta     int64
+	Before    uint64
+	After     uint64
+	Reason    string
+	Admin     string
+	TxID      string
+	Timestamp uint64
+}
+
+type BalanceAdjustmentPage struct {
+	Records             []*BalanceAdjustment
+	FetchedRecordsCount int32
+	Bookmark            string
+}
+
+// RegisterAccount opens an account with a zero balance for the caller, recording its MSP ID and
+// the enrollment ID of its certificate. It fails if the account already exists, so it never touches
+// existing funds and can safely be retried.
+func (s *SmartContract) RegisterAccount(ctx contractapi.TransactionContextInterface) (*AccountInfo, error) {
+	account, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client id: %v", err)
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSPID: %v", err)
+	}
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client certificate: %v", err)
+	}
+	exists, err := accountExists(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("failed to RegisterAccount, account already exists\n")
+	}
+	createTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getTxTime for RegisterAccount: %v\n", err)
+	}
+
+	info := &AccountInfo{
+		Account:    account,
+		MSPID:      mspID,
+		CreateTime: createTime,
+	}
+	if cert != nil {
+		info.EnrollmentID = cert.Subject.CommonName
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(AccountPrefix, []string{account})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data %v", err)
+	}
+	err = ctx.GetStub().PutState(key, jvalue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to PutState for RegisterAccount: %v\n", err)
+	}
+	err = putAccountBalance(ctx, &AccountBalance{Account: account}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to PutState for RegisterAccount: %v\n", err)
+	}
+	err = emitEvent(ctx, AccountRegisteredEvent, info)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// GetAccount returns the registration record of account. Accounts opened by InitAccountBalance have none.
+func (s *SmartContract) GetAccount(ctx contractapi.TransactionContextInterface, account string) (*AccountInfo, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(AccountPrefix, []string{account})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+	}
+	if len(jvalue) == 0 {
+		return nil, fmt.Errorf("Account not registered\n")
+	}
+	info := &AccountInfo{}
+	err = json.Unmarshal(jvalue, info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data %v", err)
+	}
+	return info, nil
+}
+
+// AdjustBalance credits (positive delta) or debits (negative delta) an existing account, only the admin
+// can adjust. The currency is minted or burned accordingly, and every adjustment is kept with its reason.
+func (s *SmartContract) AdjustBalance(ctx contractapi.TransactionContextInterface, account string, delta int64, reason string) (*BalanceAdjustment, error) {
+	err := authorization(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to AdjustBalance, not authenticated: %v\n", err)
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("failed to AdjustBalance, a reason is required\n")
+	}
+	if delta == 0 {
+		return nil, fmt.Errorf("failed to AdjustBalance, zero adjustment\n")
+	}
+	admin, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client id: %v", err)
+	}
+	timestamp, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getTxTime for AdjustBalance: %v\n", err)
+	}
+
+	var ab *AccountBalance
+	var before uint64
+	if delta > 0 {
+		ab, err = credit(ctx, account, uint64(delta))
+		if err == nil {
+			before = ab.Balance - uint64(delta)
+			err = updateTotalSupply(ctx, uint64(delta), 0)
+		}
+	} else {
+		err = updateTotalSupply(ctx, 0, uint64(-delta))
+		if err == nil {
+			ab, err = debit(ctx, account, uint64(-delta))
+		}
+		if err == nil {
+			before = ab.Balance + uint64(-delta)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to AdjustBalance: %v\n", err)
+	}
+
+	adjustment := &BalanceAdjustment{
+		Account:   account,
+		Delta:     delta,
+		Before:    before,
+		After:     ab.Balance,
+		Reason:    reason,
+		Admin:     admin,
+		TxID:      ctx.GetStub().GetTxID(),
+		Timestamp: timestamp,
+	}
+	// zero padded so that the keys, and GetBalanceAdjustments, are in time order
+	key, err := ctx.GetStub().CreateCompositeKey(BalanceAdjustmentPrefix, []string{account, fmt.Sprintf("%020d", timestamp), adjustment.TxID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := json.Marshal(adjustment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data %v", err)
+	}
+	err = ctx.GetStub().PutState(key, jvalue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to PutState for AdjustBalance: %v\n", err)
+	}
+	err = emitEvent(ctx, BalanceAdjustedEvent, adjustment)
+	if err != nil {
+		return nil, err
+	}
+	return adjustment, nil
+}
+
+// GetBalanceAdjustments returns one page of the AdjustBalance records of account, oldest first.
+// Adjustments with the same transaction time are in TxID order.
+func (s *SmartContract) GetBalanceAdjustments(ctx contractapi.TransactionContextInterface, account string, pageSize int32, bookmark string) (*BalanceAdjustmentPage, error) {
+	err := checkPageSize(pageSize)
+	if err != nil {
+		return nil, err
+	}
+	iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(BalanceAdjustmentPrefix, []string{account}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetStateByPartialCompositeKeyWithPagination for GetBalanceAdjustments: %v\n", err)
+	}
+	defer iter.Close()
+
+	page := &BalanceAdjustmentPage{Records: []*BalanceAdjustment{}, FetchedRecordsCount: meta.FetchedRecordsCount, Bookmark: meta.Bookmark}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate for GetBalanceAdjustments: %v\n", err)
+		}
+		adjustment := &BalanceAdjustment{}
+		err = json.Unmarshal(kv.Value, adjustment)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data %v", err)
+		}
+		page.Records = append(page.Records, adjustment)
+	}
+	return page, nil
+}
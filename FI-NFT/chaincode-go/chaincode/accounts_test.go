@@ -0,0 +1,90 @@
+package chaincode
+
+import "testing"
+
+func TestRegisterAccountNeverOverwrites(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	ctx := l.ctx("alice")
+	info, err := s.RegisterAccount(ctx)
+	mustSucceed(t, err)
+	if info.Account != "alice" || info.MSPID != "Org2MSP" || info.CreateTime != l.nowMillis() {
+		t.Fatalf("registered %+v", info)
+	}
+	if names := eventNames(ctx); len(names) != 2 || names[1] != AccountRegisteredEvent {
+		t.Fatalf("events are %v", names)
+	}
+	l.expectBalance("alice", 0)
+
+	_, err = s.AdjustBalance(l.adminCtx(), "alice", 100, "registration")
+	mustSucceed(t, err)
+	_, err = s.RegisterAccount(l.ctx("alice"))
+	mustFail(t, err, "account already exists")
+	mustFail(t, s.InitAccountBalance(l.adminCtx(), "alice", 500), "account already exists")
+	l.expectBalance("alice", 100)
+
+	got, err := s.GetAccount(l.ctx("reader"), "alice")
+	mustSucceed(t, err)
+	if *got != *info {
+		t.Fatalf("stored %+v, registered %+v", got, info)
+	}
+}
+
+func TestAdjustBalanceNeedsAdminAndReason(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(100, "alice")
+	_, err := s.AdjustBalance(l.ctx("alice"), "alice", 50, "bonus")
+	mustFail(t, err, "not authenticated")
+	_, err = s.AdjustBalance(l.adminCtx(), "alice", 50, "")
+	mustFail(t, err, "a reason is required")
+	_, err = s.AdjustBalance(l.adminCtx(), "alice", 0, "nothing")
+	mustFail(t, err, "zero adjustment")
+	_, err = s.AdjustBalance(l.adminCtx(), "nobody", 50, "bonus")
+	mustFail(t, err, "failed to getAccountBalance")
+
+	adjustment, err := s.AdjustBalance(l.adminCtx(), "alice", -30, "refund")
+	mustSucceed(t, err)
+	if adjustment.Before != 100 || adjustment.After != 70 || adjustment.Admin != "admin" || adjustment.Timestamp != l.nowMillis() {
+		t.Fatalf("adjustment is %+v", adjustment)
+	}
+	l.expectBalance("alice", 70)
+	l.expectSupply(70)
+}
+
+func TestBalanceAdjustmentsAreChronological(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(100, "alice", "bob")
+	// TxIDs are not ordered by time, the adjustments must be
+	for i, txID := range []string{"ff", "0a", "7c", "00"} {
+		ctx := l.adminCtx()
+		l.stub.TxID = txID
+		_, err := s.AdjustBalance(ctx, "alice", int64(i+1), "bonus")
+		mustSucceed(t, err)
+		l.advance(1)
+	}
+	_, err := s.AdjustBalance(l.adminCtx(), "bob", 5, "bonus")
+	mustSucceed(t, err)
+
+	page, err := s.GetBalanceAdjustments(l.ctx("reader"), "alice", 3, "")
+	mustSucceed(t, err)
+	if len(page.Records) != 3 || page.Bookmark == "" {
+		t.Fatalf("first page is %+v", page)
+	}
+	records := page.Records
+	page, err = s.GetBalanceAdjustments(l.ctx("reader"), "alice", 3, page.Bookmark)
+	mustSucceed(t, err)
+	records = append(records, page.Records...)
+	if len(records) != 4 {
+		t.Fatalf("alice has %d adjustments", len(records))
+	}
+	for i, adjustment := range records {
+		if adjustment.Account != "alice" || adjustment.Delta != int64(i+1) {
+			t.Fatalf("adjustment %d is %+v", i, adjustment)
+		}
+		if i > 0 && adjustment.Timestamp <= records[i-1].Timestamp {
+			t.Fatalf("adjustment %d at %d is not after %d", i, adjustment.Timestamp, records[i-1].Timestamp)
+		}
+	}
+}
@@ -0,0 +1,501 @@
+package chaincode
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Auction types accepted by AddAuction and stored in NFTBid.AuctionType.
+const (
+	// AuctionEnglish is the ascending auction: highest offer wins at timeout, or at once when it reaches KillPrice.
+	AuctionEnglish = "english"
+	// AuctionDutch starts asking KillPrice and decays linearly to LowerPrice over LifeTime, the first offer
+	// at or above the asking price buys the token for the asking price.
+	AuctionDutch = "dutch"
+	// AuctionFixedPrice sells to the first offer at or above KillPrice, for KillPrice.
+	AuctionFixedPrice = "fixed"
+	// AuctionSealedBid takes hidden bids through CommitBid until CommitDeadline and RevealBid until
+	// RevealDeadline, the highest revealed bid at or above LowerPrice wins and pays its own amount.
+	AuctionSealedBid = "sealed"
+)
+
+// auctionFormat is the strategy behind one auction type, Offer, TryEndBid, CancelAuction and
+// RelistAuction dispatch to it.
+type auctionFormat interface {
+	// open sets the prices and deadlines of a new or relisted auction, CreateTime and LifeTime are already set.
+	open(bid *NFTBid, lowerPrice uint64, upPrice uint64, config *Config) error
+	// offer judges an offer of price by bidder at currentTime and records it on bid.
+	offer(ctx contractapi.TransactionContextInterface, bid *NFTBid, bidder string, price uint64, currentTime uint64) error
+	// ended reports whether bid can be settled at currentTime.
+	ended(bid *NFTBid, currentTime uint64) bool
+	// hasBids reports whether any bidder has funds held for bid.
+	hasBids(ctx contractapi.TransactionContextInterface, bid *NFTBid) (bool, error)
+	// settle releases the funds held for bid, refunding losing bidders, and returns what seller is owed.
+	settle(ctx contractapi.TransactionContextInterface, bid *NFTBid, seller string) (*settlement, error)
+	// cancel refunds the funds held for bid when the seller withdraws it and returns the cancel reason.
+	cancel(ctx contractapi.TransactionContextInterface, bid *NFTBid, currentTime uint64) (string, error)
+}
+
+// settlement is the outcome of an ended auction, Winner is NonBidder if the token is not sold,
+// optionally with the Reason. The seller is paid Price plus any Forfeited deposits.
+type settlement struct {
+	Winner    string
+	Price     uint64
+	Forfeited uint64
+	Reason    string
+}
+
+var auctionFormats = map[string]auctionFormat{
+	AuctionEnglish:    englishAuction{},
+	AuctionDutch:      dutchAuction{},
+	AuctionFixedPrice: fixedPriceAuction{},
+	AuctionSealedBid:  sealedBidAuction{},
+}
+
+func getAuctionFormat(bid *NFTBid) (auctionFormat, error) {
+	if bid.AuctionType == "" {
+		return auctionFormats[AuctionEnglish], nil
+	}
+	format, ok := auctionFormats[bid.AuctionType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported auction type %s\n", bid.AuctionType)
+	}
+	return format, nil
+}
+
+type englishAuction struct{}
+
+func (englishAuction) open(bid *NFTBid, lowerPrice uint64, upPrice uint64, config *Config) error {
+	if lowerPrice > upPrice {
+		return fmt.Errorf("start price %d is above kill price %d\n", lowerPrice, upPrice)
+	}
+	bid.CurrentPrice = lowerPrice
+	bid.LowerPrice = lowerPrice
+	bid.KillPrice = upPrice
+	return nil
+}
+
+func (englishAuction) offer(ctx contractapi.TransactionContextInterface, bid *NFTBid, bidder string, price uint64, currentTime uint64) error {
+	if bid.CurrentOwner == NonBidder {
+		if price < bid.LowerPrice {
+			return fmt.Errorf("price lower than start price %d\n", bid.LowerPrice)
+		}
+	} else if minimum := minNextBid(bid); price < minimum {
+		return fmt.Errorf("price lower than minimum next bid %d\n", minimum)
+	}
+	err := placeTopBid(ctx, bid, bidder, price)
+	if err != nil {
+		return err
+	}
+	return extendSoftClose(ctx, bid, currentTime)
+}
+
+func (englishAuction) ended(bid *NFTBid, currentTime uint64) bool {
+	return bidTimedOut(bid, currentTime) || bid.CurrentPrice >= bid.KillPrice
+}
+
+func (englishAuction) hasBids(ctx contractapi.TransactionContextInterface, bid *NFTBid) (bool, error) {
+	return bid.CurrentOwner != NonBidder, nil
+}
+
+func (englishAuction) settle(ctx contractapi.TransactionContextInterface, bid *NFTBid, seller string) (*settlement, error) {
+	reserve, err := getReservePrice(ctx, bid.TokenID)
+	if err != nil {
+		return nil, err
+	}
+	//a reserve price that was not revealed in time counts as met
+	if bid.CurrentOwner == NonBidder || reserve == nil || !reserve.Revealed || bid.CurrentPrice >= reserve.Price {
+		return settleFromEscrow(ctx, bid)
+	}
+	escrow, err := getEscrow(ctx, bid.TokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getEscrow: %v\n", err)
+	}
+	_, err = credit(ctx, escrow.Bidder, escrow.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refund bidder: %v\n", err)
+	}
+	err = deleteEscrow(ctx, bid.TokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deleteEscrow: %v\n", err)
+	}
+	return &settlement{Winner: NonBidder, Reason: "reserve price not met"}, nil
+}
+
+func (f englishAuction) cancel(ctx contractapi.TransactionContextInterface, bid *NFTBid, currentTime uint64) (string, error) {
+	if bid.CurrentOwner != NonBidder && f.ended(bid, currentTime) {
+		return "", fmt.Errorf("auction has ended and must be settled\n")
+	}
+	return cancelFromEscrow(ctx, bid)
+}
+
+type dutchAuction struct{}
+
+func (dutchAuction) open(bid *NFTBid, lowerPrice uint64, upPrice uint64, config *Config) error {
+	if upPrice <= lowerPrice {
+		return fmt.Errorf("dutch auction start price %d must be higher than end price %d\n", upPrice, lowerPrice)
+	}
+	bid.CurrentPrice = upPrice
+	bid.LowerPrice = lowerPrice
+	bid.KillPrice = upPrice
+	return nil
+}
+
+func (dutchAuction) offer(ctx contractapi.TransactionContextInterface, bid *NFTBid, bidder string, price uint64, currentTime uint64) error {
+	asking := dutchPrice(bid, currentTime)
+	if price < asking {
+		return fmt.Errorf("price lower than asking price %d\n", asking)
+	}
+	return placeTopBid(ctx, bid, bidder, asking)
+}
+
+func (dutchAuction) ended(bid *NFTBid, currentTime uint64) bool {
+	return bidTimedOut(bid, currentTime) || bid.CurrentOwner != NonBidder
+}
+
+func (dutchAuction) hasBids(ctx contractapi.TransactionContextInterface, bid *NFTBid) (bool, error) {
+	return bid.CurrentOwner != NonBidder, nil
+}
+
+func (dutchAuction) settle(ctx contractapi.TransactionContextInterface, bid *NFTBid, seller string) (*settlement, error) {
+	return settleFromEscrow(ctx, bid)
+}
+
+func (f dutchAuction) cancel(ctx contractapi.TransactionContextInterface, bid *NFTBid, currentTime uint64) (string, error) {
+	if bid.CurrentOwner != NonBidder && f.ended(bid, currentTime) {
+		return "", fmt.Errorf("auction has ended and must be settled\n")
+	}
+	return cancelFromEscrow(ctx, bid)
+}
+
+// dutchPrice is the asking price of a dutch auction at currentTime.
+func dutchPrice(bid *NFTBid, currentTime uint64) uint64 {
+	if bid.LifeTime == 0 || currentTime <= bid.CreateTime {
+		return bid.KillPrice
+	}
+	elapsed := currentTime - bid.CreateTime
+	if elapsed >= bid.LifeTime {
+		return bid.LowerPrice
+	}
+	//elapsed < LifeTime keeps the quotient below KillPrice - LowerPrice, so Div64 cannot overflow
+	hi, lo := bits.Mul64(bid.KillPrice-bid.LowerPrice, elapsed)
+	drop, _ := bits.Div64(hi, lo, bid.LifeTime)
+	return bid.KillPrice - drop
+}
+
+type fixedPriceAuction struct{}
+
+func (fixedPriceAuction) open(bid *NFTBid, lowerPrice uint64, upPrice uint64, config *Config) error {
+	bid.CurrentPrice = upPrice
+	bid.LowerPrice = upPrice
+	bid.KillPrice = upPrice
+	return nil
+}
+
+func (fixedPriceAuction) offer(ctx contractapi.TransactionContextInterface, bid *NFTBid, bidder string, price uint64, currentTime uint64) error {
+	if price < bid.KillPrice {
+		return fmt.Errorf("price lower than fixed price %d\n", bid.KillPrice)
+	}
+	return placeTopBid(ctx, bid, bidder, bid.KillPrice)
+}
+
+func (fixedPriceAuction) ended(bid *NFTBid, currentTime uint64) bool {
+	return bidTimedOut(bid, currentTime) || bid.CurrentOwner != NonBidder
+}
+
+func (fixedPriceAuction) hasBids(ctx contractapi.TransactionContextInterface, bid *NFTBid) (bool, error) {
+	return bid.CurrentOwner != NonBidder, nil
+}
+
+func (fixedPriceAuction) settle(ctx contractapi.TransactionContextInterface, bid *NFTBid, seller string) (*settlement, error) {
+	return settleFromEscrow(ctx, bid)
+}
+
+func (f fixedPriceAuction) cancel(ctx contractapi.TransactionContextInterface, bid *NFTBid, currentTime uint64) (string, error) {
+	if bid.CurrentOwner != NonBidder && f.ended(bid, currentTime) {
+		return "", fmt.Errorf("auction has ended and must be settled\n")
+	}
+	return cancelFromEscrow(ctx, bid)
+}
+
+// placeTopBid makes bidder the top bidder of bid at price, holding its funds and refunding the previous one.
+func placeTopBid(ctx contractapi.TransactionContextInterface, bid *NFTBid, bidder string, price uint64) error {
+	err := holdBidFunds(ctx, bid, bidder, price)
+	if err != nil {
+		return fmt.Errorf("failed to hold funds: %v\n", err)
+	}
+	err = emitBidPlaced(ctx, bid, bidder, price)
+	if err != nil {
+		return err
+	}
+	err = recordBid(ctx, bid, bidder, price)
+	if err != nil {
+		return fmt.Errorf("failed to record bid: %v\n", err)
+	}
+	bid.CurrentPrice = price
+	bid.CurrentOwner = bidder
+	return nil
+}
+
+// minNextBid is the lowest price that outbids the top bid of an English auction: CurrentPrice raised by
+// the larger of the two increments and at least 1, but never above KillPrice.
+func minNextBid(bid *NFTBid) uint64 {
+	increment := bid.MinIncrement
+	if byShare := shareOf(bid.CurrentPrice, bid.MinIncrementBasisPoints); byShare > increment {
+		increment = byShare
+	}
+	if increment == 0 {
+		increment = 1
+	}
+	if bid.CurrentPrice+increment > bid.KillPrice && bid.KillPrice > bid.CurrentPrice {
+		return bid.KillPrice
+	}
+	return bid.CurrentPrice + increment
+}
+
+// extendSoftClose pushes the end of bid back by SoftCloseExtension when a bid arrives within
+// SoftCloseWindow of the end, as long as the total extension stays within MaxExtension.
+func extendSoftClose(ctx contractapi.TransactionContextInterface, bid *NFTBid, currentTime uint64) error {
+	if bid.SoftCloseWindow == 0 || bid.Extended >= bid.MaxExtension {
+		return nil
+	}
+	end := bid.CreateTime + bid.LifeTime
+	if currentTime+bid.SoftCloseWindow < end {
+		return nil
+	}
+	extension := bid.SoftCloseExtension
+	if extension > bid.MaxExtension-bid.Extended {
+		extension = bid.MaxExtension - bid.Extended
+	}
+	bid.LifeTime += extension
+	bid.Extended += extension
+	return emitEvent(ctx, AuctionExtendedEvent, &AuctionExtended{
+		TokenID:  bid.TokenID,
+		EndTime:  bid.CreateTime + bid.LifeTime,
+		Extended: bid.Extended,
+	})
+}
+
+// SetSoftClose turns on anti-sniping for the English auction of tokenID: every bid placed in the last
+// windowMinute minutes extends the auction by extensionMinute minutes, up to maxExtensionMinute in total.
+// Only the seller can set it, before the first bid. A zero windowMinute turns it off.
+func (s *SmartContract) SetSoftClose(ctx contractapi.TransactionContextInterface, tokenID string, windowMinute uint64, extensionMinute uint64, maxExtensionMinute uint64) (*NFTBid, error) {
+	operator, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client id: %v", err)
+	}
+	bid, err := getBid(ctx, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getBid for SetSoftClose: %v\n", err)
+	}
+	nft, err := getNFT(ctx, auctionedToken(bid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to getNFT for SetSoftClose: %v\n", err)
+	}
+	if auctionSeller(nft, bid) != operator {
+		return nil, fmt.Errorf("failed to SetSoftClose, not Owner\n")
+	}
+	if bid.AuctionType != "" && bid.AuctionType != AuctionEnglish {
+		return nil, fmt.Errorf("failed to SetSoftClose, not an English auction\n")
+	}
+	if bid.CurrentOwner != NonBidder {
+		return nil, fmt.Errorf("failed to SetSoftClose, auction already has a bid\n")
+	}
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getConfig for SetSoftClose: %v\n", err)
+	}
+	if windowMinute > config.MaxLifeTime || extensionMinute > config.MaxLifeTime || maxExtensionMinute > config.MaxLifeTime {
+		return nil, fmt.Errorf("failed to SetSoftClose, time exceed max time(%d min)\n", config.MaxLifeTime)
+	}
+
+	bid.SoftCloseWindow = windowMinute * 60 * 1000
+	bid.SoftCloseExtension = extensionMinute * 60 * 1000
+	bid.MaxExtension = maxExtensionMinute * 60 * 1000
+	err = putBid(ctx, bid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to PutState for SetSoftClose: %v\n", err)
+	}
+	return bid, nil
+}
+
+// settleFromEscrow pays out the single escrow of the top bidder of bid.
+func settleFromEscrow(ctx contractapi.TransactionContextInterface, bid *NFTBid) (*settlement, error) {
+	if bid.CurrentOwner == NonBidder {
+		return &settlement{Winner: NonBidder}, nil
+	}
+	escrow, err := getEscrow(ctx, bid.TokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getEscrow: %v\n", err)
+	}
+	if escrow.Bidder != bid.CurrentOwner || escrow.Amount != bid.CurrentPrice {
+		return nil, fmt.Errorf("escrow {%s: %d} does not match bid {%s: %d}\n", escrow.Bidder, escrow.Amount, bid.CurrentOwner, bid.CurrentPrice)
+	}
+	err = deleteEscrow(ctx, bid.TokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deleteEscrow: %v\n", err)
+	}
+	return &settlement{Winner: escrow.Bidder, Price: escrow.Amount}, nil
+}
+
+// cancelFromEscrow refunds the top bidder of bid, if any, and charges the seller Config.CancelPenaltyBasisPoints
+// of the bid in favour of the bidder.
+func cancelFromEscrow(ctx contractapi.TransactionContextInterface, bid *NFTBid) (string, error) {
+	if bid.CurrentOwner == NonBidder {
+		return "cancelled by seller", nil
+	}
+	seller, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client id: %v", err)
+	}
+	escrow, err := getEscrow(ctx, bid.TokenID)
+	if err != nil {
+		return "", fmt.Errorf("failed to getEscrow: %v\n", err)
+	}
+	config, err := getConfig(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to getConfig: %v\n", err)
+	}
+	penalty := shareOf(escrow.Amount, config.CancelPenaltyBasisPoints)
+	if escrow.Bidder == seller {
+		penalty = 0
+	}
+	if penalty > 0 {
+		_, err = debit(ctx, seller, penalty)
+		if err != nil {
+			return "", fmt.Errorf("failed to take out penalty from seller: %v\n", err)
+		}
+	}
+	_, err = credit(ctx, escrow.Bidder, escrow.Amount+penalty)
+	if err != nil {
+		return "", fmt.Errorf("failed to refund bidder: %v\n", err)
+	}
+	err = deleteEscrow(ctx, bid.TokenID)
+	if err != nil {
+		return "", fmt.Errorf("failed to deleteEscrow: %v\n", err)
+	}
+	return fmt.Sprintf("cancelled by seller, penalty %d paid to bidder", penalty), nil
+}
+
+// AddAuction puts tokenID on sale with one of the auction types. lowerPrice and upPrice are the start and
+// kill prices of an English auction, the end and start prices of a Dutch auction, and upPrice is the price
+// of a fixed-price listing. lowerPrice is the minimum bid of a sealed-bid auction, whose commit phase lasts
+// lifeMinute and is followed by a reveal phase of Config.SealedRevealLifeTime minutes.
+func (s *SmartContract) AddAuction(ctx contractapi.TransactionContextInterface, tokenID string, auctionType string, lowerPrice uint64, upPrice uint64, lifeMinute uint64) (*NFTBid, error) {
+	return addAuction(ctx, tokenID, auctionType, lowerPrice, upPrice, lifeMinute, 0)
+}
+
+// addAuction lists tokenID for the caller. quantity is 0 for a unique NFT and the number of editions
+// sold for a token class, which are taken out of the caller's balance and listed under editionLotID.
+func addAuction(ctx contractapi.TransactionContextInterface, tokenID string, auctionType string, lowerPrice uint64, upPrice uint64, lifeMinute uint64, quantity uint64) (*NFTBid, error) {
+	// check operator==NFT.Owner
+	operator, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client id: %v", err)
+	}
+	auctionID, classID := tokenID, ""
+	if quantity > 0 {
+		auctionID, classID = editionLotID(tokenID, operator), tokenID
+	}
+	exists, _ := bidExists(ctx, auctionID)
+	if exists {
+		return nil, fmt.Errorf("Bid already exists\n")
+	}
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getConfig for AddBid: %v\n", err)
+	}
+	if lifeMinute > config.MaxLifeTime {
+		return nil, fmt.Errorf("failed to AddBid, life time exceed max time(%d min)\n", config.MaxLifeTime)
+	}
+	nft, err := getNFT(ctx, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nft %v\n", err)
+	}
+	if nft.Supply == 0 && quantity > 0 {
+		return nil, fmt.Errorf("failed to AddBid, %s is a unique token\n", tokenID)
+	}
+	if nft.Supply > 0 && quantity == 0 {
+		return nil, fmt.Errorf("failed to AddBid, %s is a token class, use AddEditionAuction\n", tokenID)
+	}
+	if nft.Supply == 0 && nft.Owner != operator {
+		return nil, fmt.Errorf("failed to AddBid, not Owner\n")
+	}
+
+	createTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getTxTime for AddBid: %v\n", err)
+	}
+	life := lifeMinute * 60 * 1000
+
+	fmt.Printf("%v AddBid, with lifeTime %v\n", createTime, life)
+	newbid := &NFTBid{
+		TokenID:      auctionID,
+		ClassID:      classID,
+		Seller:       operator,
+		Quantity:     quantity,
+		AuctionType:  auctionType,
+		CurrentOwner: NonBidder,
+		CreateTime:   createTime,
+		LifeTime:     life,
+	}
+	format, err := getAuctionFormat(newbid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to AddBid: %v\n", err)
+	}
+	err = format.open(newbid, lowerPrice, upPrice, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to AddBid: %v\n", err)
+	}
+
+	if quantity > 0 {
+		err = debitEditions(ctx, tokenID, operator, quantity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to AddBid: %v\n", err)
+		}
+	}
+	err = putBid(ctx, newbid)
+	fmt.Printf("AddBid {%s : %v}\n", auctionID, newbid)
+	if err != nil {
+		return nil, fmt.Errorf("falied to add new Bid %v\n", err)
+	}
+
+	err = addBidsToList(ctx, auctionID)
+	if err != nil {
+		fmt.Println(err)
+		return nil, fmt.Errorf("failed to add new bid to list %v\n", err)
+	}
+	err = emitEvent(ctx, AuctionCreatedEvent, &AuctionCreated{
+		TokenID:     auctionID,
+		ClassID:     classID,
+		AuctionType: auctionType,
+		Seller:      operator,
+		LowerPrice:  newbid.LowerPrice,
+		KillPrice:   newbid.KillPrice,
+		CreateTime:  createTime,
+		LifeTime:    life,
+		Quantity:    quantity,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newbid, nil
+}
+
+// auctionedToken returns the tokenID of the NFT or token class sold by bid.
+func auctionedToken(bid *NFTBid) string {
+	if bid.ClassID != "" {
+		return bid.ClassID
+	}
+	return bid.TokenID
+}
+
+// auctionSeller returns the account that listed bid, the owner of nft for auctions listed before NFTBid.Seller.
+func auctionSeller(nft *NFT, bid *NFTBid) string {
+	if bid.Seller != "" {
+		return bid.Seller
+	}
+	return nft.Owner
+}
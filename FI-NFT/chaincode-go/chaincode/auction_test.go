@@ -0,0 +1,147 @@
+package chaincode
+
+import "testing"
+
+func TestDutchAuctionSellsAtAskingPrice(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", AuctionDutch, 500, 100, 10)
+	mustFail(t, err, "must be higher than end price")
+	_, err = s.AddAuction(l.ctx("seller"), "1", AuctionDutch, 100, 500, 10)
+	mustSucceed(t, err)
+
+	// the asking price falls from 500 to 100 over 10 minutes
+	l.advance(5)
+	mustFail(t, s.Offer(l.ctx("alice"), 299, "1"), "asking price 300")
+	mustSucceed(t, s.Offer(l.ctx("alice"), 450, "1"))
+	l.expectBalance("alice", 700)
+	mustSucceed(t, s.TryEndBid(l.ctx("reader"), "1"))
+	if owner := l.owner("1"); owner != "alice" {
+		t.Fatalf("owner is %s", owner)
+	}
+	l.expectBalance("seller", 1300)
+}
+
+func TestDutchPriceDoesNotOverflow(t *testing.T) {
+	lifeTime := uint64(3 * 24 * 60 * 60 * 1000)
+	bid := &NFTBid{KillPrice: 1 << 40, LowerPrice: 0, CreateTime: 1000, LifeTime: lifeTime}
+	if price := dutchPrice(bid, 1000+lifeTime/2); price != 549755813888 {
+		t.Fatalf("asking price at half time is %d", price)
+	}
+	if price := dutchPrice(bid, 1000+lifeTime); price != 0 {
+		t.Fatalf("asking price at the end is %d", price)
+	}
+}
+
+func TestFixedPriceAuctionSellsToFirstOffer(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice", "bob")
+	l.seedNFT("1", "seller")
+	bid, err := s.AddAuction(l.ctx("seller"), "1", AuctionFixedPrice, 0, 200, 10)
+	mustSucceed(t, err)
+	if bid.LowerPrice != 200 || bid.KillPrice != 200 {
+		t.Fatalf("fixed price auction is %+v", bid)
+	}
+	mustFail(t, s.Offer(l.ctx("alice"), 199, "1"), "lower than fixed price")
+	mustSucceed(t, s.Offer(l.ctx("alice"), 250, "1"))
+	l.expectBalance("alice", 800)
+	mustFail(t, s.Offer(l.ctx("bob"), 300, "1"), "auction has ended")
+	mustSucceed(t, s.FindBidToEnd(l.ctx("reader")))
+	if owner := l.owner("1"); owner != "alice" {
+		t.Fatalf("owner is %s", owner)
+	}
+}
+
+func TestAddAuctionRejectsUnknownType(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.seedNFT("1", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", "vickrey", 0, 100, 10)
+	mustFail(t, err, "unsupported auction type vickrey")
+	_, err = s.AddAuction(l.ctx("alice"), "1", AuctionEnglish, 0, 100, 10)
+	mustFail(t, err, "not Owner")
+}
+
+func TestEnglishAuctionEndsAtKillPrice(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", "", 100, 300, 10)
+	mustSucceed(t, err)
+	mustSucceed(t, s.Offer(l.ctx("alice"), 300, "1"))
+	phase, err := s.GetAuctionPhase(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if phase != PhaseEnded {
+		t.Fatalf("phase is %s at the kill price", phase)
+	}
+	mustSucceed(t, s.TryEndBid(l.ctx("reader"), "1"))
+	if owner := l.owner("1"); owner != "alice" {
+		t.Fatalf("owner is %s", owner)
+	}
+}
+
+func TestSoftCloseExtendsLateBids(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice", "bob")
+	l.seedNFT("1", "seller")
+	bid, err := s.AddAuction(l.ctx("seller"), "1", AuctionEnglish, 10, 500, 10)
+	mustSucceed(t, err)
+	_, err = s.SetSoftClose(l.ctx("alice"), "1", 2, 3, 5)
+	mustFail(t, err, "not Owner")
+	_, err = s.SetSoftClose(l.ctx("seller"), "1", 2, 3, 5)
+	mustSucceed(t, err)
+
+	// a bid before the window does not extend
+	mustSucceed(t, s.Offer(l.ctx("alice"), 20, "1"))
+	_, err = s.SetSoftClose(l.ctx("seller"), "1", 2, 3, 5)
+	mustFail(t, err, "already has a bid")
+	result, err := s.CanBidEnd(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if result.Extended != 0 {
+		t.Fatalf("extended by %d", result.Extended)
+	}
+
+	l.advance(9)
+	ctx := l.ctx("bob")
+	mustSucceed(t, s.Offer(ctx, 30, "1"))
+	if names := eventNames(ctx); names[len(names)-1] != AuctionExtendedEvent {
+		t.Fatalf("late bid raised %v", names)
+	}
+	result, err = s.CanBidEnd(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if result.CanEnd || result.Extended != 3*60*1000 || result.EndTime != bid.CreateTime+13*60*1000 {
+		t.Fatalf("CanBidEnd is %+v after the first extension", result)
+	}
+
+	// the second extension is capped by the 5 minute maximum
+	l.advance(3)
+	_, err = s.UpdateBid(l.ctx("alice"), "1", 40)
+	mustSucceed(t, err)
+	result, err = s.CanBidEnd(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if result.Extended != 5*60*1000 || result.EndTime != bid.CreateTime+15*60*1000 {
+		t.Fatalf("CanBidEnd is %+v after the second extension", result)
+	}
+
+	l.advance(4)
+	mustFail(t, s.Offer(l.ctx("bob"), 50, "1"), "auction has ended")
+	mustSucceed(t, s.TryEndBid(l.ctx("reader"), "1"))
+	if owner := l.owner("1"); owner != "alice" {
+		t.Fatalf("owner is %s", owner)
+	}
+}
+
+func TestSetSoftCloseOnlyOnEnglishAuctions(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.seedNFT("1", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", AuctionDutch, 10, 500, 10)
+	mustSucceed(t, err)
+	_, err = s.SetSoftClose(l.ctx("seller"), "1", 2, 3, 5)
+	mustFail(t, err, "not an English auction")
+}
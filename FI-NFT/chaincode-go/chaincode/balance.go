@@ -0,0 +1,116 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// InsufficientFundsError is returned by debit when Account holds less than Amount.
+type InsufficientFundsError struct {
+	Account string
+	Balance uint64
+	Amount  uint64
+}
+
+func (e *InsufficientFundsError) Error() string {
+	return fmt.Sprintf("insufficient funds on %s, has: %d, need: %d", e.Account, e.Balance, e.Amount)
+}
+
+// BalanceOverflowError is returned by credit when adding Amount to Balance exceeds the uint64 range.
+type BalanceOverflowError struct {
+	Account string
+	Balance uint64
+	Amount  uint64
+}
+
+func (e *BalanceOverflowError) Error() string {
+	return fmt.Sprintf("balance overflow on %s, has: %d, adding: %d", e.Account, e.Balance, e.Amount)
+}
+
+// credit adds amount to the balance of an existing account.
+func credit(ctx contractapi.TransactionContextInterface, account string, amount uint64) (*AccountBalance, error) {
+	ab, err := getAccountBalance(ctx, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getAccountBalance: %v\n", err)
+	}
+	if ab.Balance > math.MaxUint64-amount {
+		return nil, &BalanceOverflowError{Account: account, Balance: ab.Balance, Amount: amount}
+	}
+	before := ab.Balance
+	ab.Balance += amount
+	err = putAccountBalance(ctx, ab, before)
+	if err != nil {
+		return nil, err
+	}
+	return ab, nil
+}
+
+// debit takes amount out of the balance of an existing account, never below zero.
+func debit(ctx contractapi.TransactionContextInterface, account string, amount uint64) (*AccountBalance, error) {
+	ab, err := getAccountBalance(ctx, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getAccountBalance: %v\n", err)
+	}
+	if ab.Balance < amount {
+		return nil, &InsufficientFundsError{Account: account, Balance: ab.Balance, Amount: amount}
+	}
+	before := ab.Balance
+	ab.Balance -= amount
+	err = putAccountBalance(ctx, ab, before)
+	if err != nil {
+		return nil, err
+	}
+	return ab, nil
+}
+
+// putAccountBalance stores ab and raises BalanceChanged from before. The new balance is remembered
+// for the rest of the transaction, as GetState does not return the writes of the current transaction.
+func putAccountBalance(ctx contractapi.TransactionContextInterface, ab *AccountBalance, before uint64) error {
+	key, err := ctx.GetStub().CreateCompositeKey(BalancePrefix, []string{ab.Account})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := json.Marshal(ab)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data %v", err)
+	}
+	err = ctx.GetStub().PutState(key, jvalue)
+	fmt.Printf("Update Account (%v,%v)\n", key, ab)
+	if err != nil {
+		return fmt.Errorf("failed to PutState %v\n", err)
+	}
+	if tc, ok := ctx.(*TransactionContext); ok {
+		if tc.balances == nil {
+			tc.balances = map[string]AccountBalance{}
+		}
+		tc.balances[ab.Account] = *ab
+	}
+	return emitEvent(ctx, BalanceChangedEvent, &BalanceChanged{Account: ab.Account, Before: before, After: ab.Balance})
+}
+
+// cachedAccountBalance returns the balance of account written earlier in the transaction, if any.
+func cachedAccountBalance(ctx contractapi.TransactionContextInterface, account string) (*AccountBalance, bool) {
+	tc, ok := ctx.(*TransactionContext)
+	if !ok {
+		return nil, false
+	}
+	ab, ok := tc.balances[account]
+	if !ok {
+		return nil, false
+	}
+	return &ab, true
+}
+
+// shareOf returns basisPoints (1/10000) of amount without overflowing the multiplication.
+func shareOf(amount uint64, basisPoints uint64) uint64 {
+	if basisPoints >= 10000 {
+		return amount
+	}
+	hi, lo := bits.Mul64(amount, basisPoints)
+	share, _ := bits.Div64(hi, lo, 10000)
+	return share
+}
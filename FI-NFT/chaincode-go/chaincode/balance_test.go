@@ -0,0 +1,119 @@
+package chaincode
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// pendingStub keeps the writes of a transaction until commit, like a peer that does not let a
+// transaction read its own writes.
+type pendingStub struct {
+	*testStub
+	keys    []string
+	values  map[string][]byte
+	deleted map[string]bool
+}
+
+func (s *pendingStub) PutState(key string, value []byte) error {
+	s.keys = append(s.keys, key)
+	s.values[key] = value
+	delete(s.deleted, key)
+	return nil
+}
+
+func (s *pendingStub) DelState(key string) error {
+	s.keys = append(s.keys, key)
+	s.deleted[key] = true
+	return nil
+}
+
+func (s *pendingStub) commit() {
+	for _, key := range s.keys {
+		if s.deleted[key] {
+			s.testStub.DelState(key)
+		} else {
+			s.testStub.PutState(key, s.values[key])
+		}
+	}
+}
+
+// pendingCtx starts a transaction like ctx whose writes are applied by commit.
+func (l *testLedger) pendingCtx(account string) (*TransactionContext, *pendingStub) {
+	ctx := l.ctx(account)
+	stub := &pendingStub{testStub: ctx.GetStub().(*testStub), values: map[string][]byte{}, deleted: map[string]bool{}}
+	ctx.SetStub(stub)
+	return ctx, stub
+}
+
+func TestDebitAndCreditReturnTypedErrors(t *testing.T) {
+	l := newTestLedger(t)
+	l.openAccounts(100, "alice")
+	ctx := l.ctx("alice")
+
+	_, err := debit(ctx, "alice", 101)
+	var insufficient *InsufficientFundsError
+	if !errors.As(err, &insufficient) || insufficient.Balance != 100 || insufficient.Amount != 101 {
+		t.Fatalf("debit beyond the balance returned %v", err)
+	}
+	_, err = credit(ctx, "alice", math.MaxUint64)
+	var overflow *BalanceOverflowError
+	if !errors.As(err, &overflow) || overflow.Balance != 100 || overflow.Amount != math.MaxUint64 {
+		t.Fatalf("credit beyond uint64 returned %v", err)
+	}
+	_, err = credit(ctx, "nobody", 1)
+	mustFail(t, err, "failed to getAccountBalance")
+	l.expectBalance("alice", 100)
+
+	ab, err := debit(ctx, "alice", 100)
+	mustSucceed(t, err)
+	if ab.Balance != 0 {
+		t.Fatalf("balance after debit is %d", ab.Balance)
+	}
+	if names := eventNames(ctx); len(names) != 1 || names[0] != BalanceChangedEvent {
+		t.Fatalf("events are %v", names)
+	}
+}
+
+func TestShareOfDoesNotOverflow(t *testing.T) {
+	if share := shareOf(math.MaxUint64, 5000); share != math.MaxUint64/2 {
+		t.Fatalf("half of MaxUint64 is %d", share)
+	}
+	if share := shareOf(1000, 250); share != 25 {
+		t.Fatalf("2.5%% of 1000 is %d", share)
+	}
+	if share := shareOf(1000, 20000); share != 1000 {
+		t.Fatalf("share above 10000 basis points is %d", share)
+	}
+}
+
+func TestBalanceUpdatesSeeEarlierUpdatesOfTheTransaction(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	l.seedNFT("2", "seller")
+	for _, tokenID := range []string{"1", "2"} {
+		_, err := s.AddAuction(l.ctx("seller"), tokenID, AuctionFixedPrice, 0, 100, 10)
+		mustSucceed(t, err)
+		mustSucceed(t, s.Offer(l.ctx("alice"), 100, tokenID))
+	}
+
+	// both sales pay the seller in one transaction, the second must not overwrite the first
+	ctx, stub := l.pendingCtx("reader")
+	mustSucceed(t, s.FindBidToEnd(ctx))
+	stub.commit()
+	l.expectBalance("seller", 1200)
+
+	ctx, stub = l.pendingCtx("alice")
+	mustSucceed(t, s.TransferCurrency(ctx, "alice", 50))
+	stub.commit()
+	l.expectBalance("alice", 800)
+
+	ctx, stub = l.pendingCtx("alice")
+	err := s.TransferCurrency(ctx, "seller", 801)
+	mustFail(t, err, "insufficient funds on alice, has: 800, need: 801")
+	stub.commit()
+	l.expectBalance("alice", 800)
+	l.expectBalance("seller", 1200)
+}
@@ -0,0 +1,165 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	gocid "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// CIDIndexPrefix maps the canonical CID of every minted content to its single token.
+const CIDIndexPrefix = "cid~tokenID"
+
+// supportedCodecs are the content codecs accepted for a minted CID, by multicodec code.
+var supportedCodecs = map[uint64]string{
+	gocid.Raw:         "raw",
+	gocid.DagProtobuf: "dag-pb",
+	gocid.DagCBOR:     "dag-cbor",
+}
+
+// supportedHashes are the digest lengths, in bytes, of the hash functions accepted for a minted CID.
+// Truncated digests are rejected.
+var supportedHashes = map[uint64]int{
+	mh.SHA2_256:         32,
+	mh.SHA2_512:         64,
+	mh.SHA3_256:         32,
+	mh.BLAKE2B_MIN + 31: 32, // blake2b-256
+}
+
+// ContentID is a CID in the canonical form stored on NFT.CID, with the names of its codec and hash function.
+type ContentID struct {
+	CID          string
+	Codec        string
+	HashFunction string
+}
+
+// parseCID decodes cid, a CIDv0 or a CIDv1 in any multibase, and returns it as a CIDv1 in base32,
+// so that every encoding of the same content compares equal.
+func parseCID(cid string) (*ContentID, error) {
+	decoded, err := gocid.Decode(cid)
+	if err != nil {
+		return nil, fmt.Errorf("malformed CID %q: %v\n", cid, err)
+	}
+	codec, ok := supportedCodecs[decoded.Type()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported codec 0x%x in CID %q\n", decoded.Type(), cid)
+	}
+	hash, err := mh.Decode(decoded.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("malformed multihash in CID %q: %v\n", cid, err)
+	}
+	length, ok := supportedHashes[hash.Code]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash function 0x%x in CID %q\n", hash.Code, cid)
+	}
+	if hash.Length != length {
+		return nil, fmt.Errorf("%s digest of %d bytes in CID %q, expected %d\n", hash.Name, hash.Length, cid, length)
+	}
+	return &ContentID{
+		CID:          gocid.NewCidV1(decoded.Type(), decoded.Hash()).String(),
+		Codec:        codec,
+		HashFunction: hash.Name,
+	}, nil
+}
+
+// setCID stores cid on nft in canonical form, with its codec and hash function.
+func setCID(nft *NFT, cid string) error {
+	parsed, err := parseCID(cid)
+	if err != nil {
+		return err
+	}
+	nft.CID = parsed.CID
+	nft.Codec = parsed.Codec
+	nft.HashFunction = parsed.HashFunction
+	return nil
+}
+
+// GetNFTByCID returns the token minted for the content cid, in any CID version or multibase.
+func (s *SmartContract) GetNFTByCID(ctx contractapi.TransactionContextInterface, cid string) (*NFT, error) {
+	parsed, err := parseCID(cid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetNFTByCID: %v\n", err)
+	}
+	tokenIDs, err := getIndexedTokenIDs(ctx, CIDIndexPrefix, []string{parsed.CID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to getIndexedTokenIDs for GetNFTByCID: %v\n", err)
+	}
+	if len(tokenIDs) == 0 {
+		return nil, fmt.Errorf("no NFT minted for CID %s\n", parsed.CID)
+	}
+	return getNFT(ctx, tokenIDs[0])
+}
+
+// MigrateCIDIndex indexes the tokens minted before CIDIndexPrefix existed, normalizing their CID.
+// A token whose content is already indexed for another token, or whose CID cannot be parsed, is left
+// out and can only be found by its tokenID. It returns the number of tokens indexed. Running it again is a no-op.
+func (s *SmartContract) MigrateCIDIndex(ctx contractapi.TransactionContextInterface) (int, error) {
+	err := authorization(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to MigrateCIDIndex, not authenticated: %v\n", err)
+	}
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey(NFTPrefix, []string{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to GetStateByPartialCompositeKey for MigrateCIDIndex: %v\n", err)
+	}
+	defer iter.Close()
+
+	//the index is read from the ledger, which does not show the writes of this transaction
+	indexed := map[string]bool{}
+	migrated := 0
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return 0, fmt.Errorf("failed to iterate nfts: %v\n", err)
+		}
+		nft := &NFT{}
+		err = json.Unmarshal(kv.Value, nft)
+		if err != nil {
+			return 0, fmt.Errorf("failed to unmarshal data %v", err)
+		}
+		if setCID(nft, nft.CID) != nil || indexed[nft.CID] {
+			continue
+		}
+		tokenIDs, err := getIndexedTokenIDs(ctx, CIDIndexPrefix, []string{nft.CID})
+		if err != nil {
+			return 0, fmt.Errorf("failed to getIndexedTokenIDs for MigrateCIDIndex: %v\n", err)
+		}
+		if len(tokenIDs) != 0 {
+			//already indexed, for this token or another one
+			continue
+		}
+		err = putNFT(ctx, nft)
+		if err != nil {
+			return 0, fmt.Errorf("failed to PutState for MigrateCIDIndex: %v\n", err)
+		}
+		err = addCIDToIndex(ctx, nft.CID, nft.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to index nft %s: %v\n", nft.ID, err)
+		}
+		indexed[nft.CID] = true
+		migrated++
+	}
+	return migrated, nil
+}
+
+// checkContentUnique fails if content cid, in canonical form, was already minted as a token.
+func checkContentUnique(ctx contractapi.TransactionContextInterface, cid string) error {
+	tokenIDs, err := getIndexedTokenIDs(ctx, CIDIndexPrefix, []string{cid})
+	if err != nil {
+		return fmt.Errorf("failed to getIndexedTokenIDs: %v\n", err)
+	}
+	if len(tokenIDs) != 0 {
+		return fmt.Errorf("content %s already minted as token %s\n", cid, tokenIDs[0])
+	}
+	return nil
+}
+
+func addCIDToIndex(ctx contractapi.TransactionContextInterface, cid string, tokenID string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(CIDIndexPrefix, []string{cid, tokenID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	return ctx.GetStub().PutState(key, []byte{0x00})
+}
@@ -0,0 +1,130 @@
+package chaincode
+
+import (
+	"testing"
+
+	gocid "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func TestParseCIDNormalizesToCIDv1(t *testing.T) {
+	parsed, err := parseCID(testCID)
+	mustSucceed(t, err)
+	if parsed.CID != testNormalizedCID || parsed.Codec != "dag-pb" || parsed.HashFunction != "sha2-256" {
+		t.Fatalf("parsed %+v", parsed)
+	}
+	decoded, err := gocid.Decode(testNormalizedCID)
+	mustSucceed(t, err)
+	base58, err := decoded.StringOfBase('z')
+	mustSucceed(t, err)
+	for _, cid := range []string{testNormalizedCID, base58} {
+		again, err := parseCID(cid)
+		mustSucceed(t, err)
+		if *again != *parsed {
+			t.Fatalf("%s parsed as %+v, want %+v", cid, again, parsed)
+		}
+	}
+
+	blake, err := mh.Sum([]byte("content"), mh.BLAKE2B_MIN+31, -1)
+	mustSucceed(t, err)
+	parsed, err = parseCID(gocid.NewCidV1(gocid.Raw, blake).String())
+	mustSucceed(t, err)
+	if parsed.Codec != "raw" || parsed.HashFunction != "blake2b-256" {
+		t.Fatalf("parsed %+v", parsed)
+	}
+}
+
+func TestParseCIDRejectsMalformedAndUnsupported(t *testing.T) {
+	for _, cid := range []string{"", "QmBAD", "bafy", "hello"} {
+		_, err := parseCID(cid)
+		mustFail(t, err, "malformed CID")
+	}
+	sha1, err := mh.Sum([]byte("content"), mh.SHA1, -1)
+	mustSucceed(t, err)
+	_, err = parseCID(gocid.NewCidV1(gocid.Raw, sha1).String())
+	mustFail(t, err, "unsupported hash function 0x11")
+	truncated, err := mh.Sum([]byte("content"), mh.SHA2_256, 20)
+	mustSucceed(t, err)
+	_, err = parseCID(gocid.NewCidV1(gocid.Raw, truncated).String())
+	mustFail(t, err, "sha2-256 digest of 20 bytes")
+	sha256, err := mh.Sum([]byte("content"), mh.SHA2_256, -1)
+	mustSucceed(t, err)
+	_, err = parseCID(gocid.NewCidV1(gocid.GitRaw, sha256).String())
+	mustFail(t, err, "unsupported codec 0x78")
+}
+
+func TestMintRejectsMalformedCID(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(100, "minter")
+	_, err := s.MintWithCID(l.ctx("minter"), "1", "hello", "png", 10, 0)
+	mustFail(t, err, "malformed CID")
+	_, err = s.MintWithFile(l.ctx("minter"), "1", "png", "hello", 0)
+	mustFail(t, err, "malformed CID")
+	l.expectBalance("minter", 100)
+}
+
+func TestContentIsMintedOnce(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openTreasury()
+	l.openAccounts(100, "minter", "copier")
+	_, err := s.MintWithCID(l.ctx("minter"), "1", testCID, "png", 10, 0)
+	mustSucceed(t, err)
+
+	other := "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"
+	_, err = s.MintWithCID(l.ctx("copier"), "1", other, "png", 10, 0)
+	mustFail(t, err, "token 1 already exists")
+	// the same content in another CID version
+	_, err = s.MintWithCID(l.ctx("copier"), "2", testNormalizedCID, "png", 10, 0)
+	mustFail(t, err, "content "+testNormalizedCID+" already minted as token 1")
+	_, err = s.MintEditions(l.ctx("copier"), "2", testCID, "png", 10, 0, 5)
+	mustFail(t, err, "already minted as token 1")
+	l.expectBalance("copier", 100)
+
+	for _, cid := range []string{testCID, testNormalizedCID} {
+		nft, err := s.GetNFTByCID(l.ctx("reader"), cid)
+		mustSucceed(t, err)
+		if nft.ID != "1" || nft.Owner != "minter" {
+			t.Fatalf("%s is %+v", cid, nft)
+		}
+	}
+	_, err = s.GetNFTByCID(l.ctx("reader"), other)
+	mustFail(t, err, "no NFT minted for CID")
+	_, err = s.GetNFTByCID(l.ctx("reader"), "hello")
+	mustFail(t, err, "malformed CID")
+}
+
+func TestMigrateCIDIndex(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	legacyCID := "QmcRD4wkPPi6dig81r5sLj9Zm1gDCL4zgpEj9CfuRrGbzF"
+	ctx := l.adminCtx()
+	// tokens minted before the index, two of the same content and one without a valid CID
+	for tokenID, cid := range map[string]string{"L1": legacyCID, "L2": legacyCID, "L3": ""} {
+		mustSucceed(t, putNFT(ctx, &NFT{ID: tokenID, CID: cid, Owner: "owner"}))
+	}
+	_, err := s.MigrateCIDIndex(l.ctx("owner"))
+	mustFail(t, err, "not authenticated")
+
+	migrated, err := s.MigrateCIDIndex(l.adminCtx())
+	mustSucceed(t, err)
+	if migrated != 1 {
+		t.Fatalf("migrated %d tokens", migrated)
+	}
+	migrated, err = s.MigrateCIDIndex(l.adminCtx())
+	mustSucceed(t, err)
+	if migrated != 0 {
+		t.Fatalf("migrated %d tokens again", migrated)
+	}
+	nft, err := s.GetNFTByCID(l.ctx("reader"), legacyCID)
+	mustSucceed(t, err)
+	if nft.ID != "L1" || nft.Codec != "dag-pb" || nft.CID == legacyCID {
+		t.Fatalf("indexed %+v", nft)
+	}
+	nft, err = s.GetNFTByID(l.ctx("reader"), "L2")
+	mustSucceed(t, err)
+	if nft.CID != legacyCID {
+		t.Fatalf("duplicate was rewritten to %+v", nft)
+	}
+}
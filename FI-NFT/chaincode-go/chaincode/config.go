@@ -0,0 +1,169 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const ConfigPrefix = "config"
+
+// IPFS_ENDPOINT is the default IPFS API endpoint used by MintWithFile and Request.
+const IPFS_ENDPOINT = "ipfs_host:5001"
+
+// Config is the on-chain configuration read by every transaction. Until InitLedger stores it,
+// the defaults of the constants above apply and Version is 0. Each SetConfig increments Version.
+type Config struct {
+	Version     uint64
+	AdminMSPIDs []string
+	// Treasury collects the MintFee of every mint and SaleCommissionBasisPoints (1/10000) of every sale,
+	// it is an open account set by the admin and is empty until then
+	Treasury                  string
+	MintFee                   uint64
+	SaleCommissionBasisPoints uint64
+	// MaxLifeTime is the longest auction, in minutes
+	MaxLifeTime           uint64
+	IPFSEndpoints         []string
+	MaxRoyaltyBasisPoints uint64
+	// CancelPenaltyBasisPoints (1/10000) of the top bid is paid by a seller to the top bidder when cancelling
+	// an auction that already has a bid, the held bid itself is always refunded in full
+	CancelPenaltyBasisPoints uint64
+	// SealedRevealLifeTime is the reveal phase of a sealed-bid auction, in minutes
+	SealedRevealLifeTime uint64
+	UpdateTime           uint64
+}
+
+// InitLedger stores the default configuration with treasury as Config.Treasury, once. Like every later
+// change it needs an admin, which before that is a client of AdmintMSPID. It also seeds TotalSupply from
+// the currency already on the ledger, which counts balances opened before the supply was tracked.
+func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface, treasury string) (*Config, error) {
+	err := authorization(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to InitLedger, not authenticated: %v\n", err)
+	}
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getConfig for InitLedger: %v\n", err)
+	}
+	if config.Version != 0 {
+		return nil, fmt.Errorf("failed to InitLedger, already initialized at version %d\n", config.Version)
+	}
+	config.Treasury = treasury
+	err = putConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to InitLedger: %v\n", err)
+	}
+	_, err = seedTotalSupply(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seedTotalSupply for InitLedger: %v\n", err)
+	}
+	return config, nil
+}
+
+// SetConfig replaces the configuration, only an admin can set it. config.Version must be the
+// version it was read at with GetConfig, so that concurrent changes are not silently overwritten.
+func (s *SmartContract) SetConfig(ctx contractapi.TransactionContextInterface, config *Config) (*Config, error) {
+	err := authorization(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to SetConfig, not authenticated: %v\n", err)
+	}
+	current, err := getConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getConfig for SetConfig: %v\n", err)
+	}
+	if config.Version != current.Version {
+		return nil, fmt.Errorf("failed to SetConfig, config changed to version %d since version %d\n", current.Version, config.Version)
+	}
+	err = putConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to SetConfig: %v\n", err)
+	}
+	return config, nil
+}
+
+func (s *SmartContract) GetConfig(ctx contractapi.TransactionContextInterface) (*Config, error) {
+	return getConfig(ctx)
+}
+
+// getConfig returns the stored configuration, or the defaults at version 0 before InitLedger.
+// Fields added after the configuration was stored keep their default.
+func getConfig(ctx contractapi.TransactionContextInterface) (*Config, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(ConfigPrefix, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+	}
+	config := &Config{
+		AdminMSPIDs:              []string{AdmintMSPID},
+		MintFee:                  MINT_FEE,
+		MaxLifeTime:              MAX_LIFETIME,
+		IPFSEndpoints:            []string{IPFS_ENDPOINT},
+		MaxRoyaltyBasisPoints:    MAX_ROYALTY_BASIS_POINTS,
+		CancelPenaltyBasisPoints: CANCEL_PENALTY_BASIS_POINTS,
+		SealedRevealLifeTime:     SEALED_REVEAL_LIFETIME,
+	}
+	if len(jvalue) == 0 {
+		return config, nil
+	}
+	err = json.Unmarshal(jvalue, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data %v", err)
+	}
+	return config, nil
+}
+
+// putConfig validates config and stores it as the next version.
+func putConfig(ctx contractapi.TransactionContextInterface, config *Config) error {
+	if len(config.AdminMSPIDs) == 0 {
+		return fmt.Errorf("no admin MSP\n")
+	}
+	if config.Treasury == "" {
+		return fmt.Errorf("empty treasury account\n")
+	}
+	exists, err := accountExists(ctx, config.Treasury)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("treasury account %s is not open\n", config.Treasury)
+	}
+	if config.MaxLifeTime == 0 {
+		return fmt.Errorf("max life time must be positive\n")
+	}
+	if len(config.IPFSEndpoints) == 0 {
+		return fmt.Errorf("no IPFS endpoint\n")
+	}
+	if config.SaleCommissionBasisPoints+config.MaxRoyaltyBasisPoints > 10000 {
+		return fmt.Errorf("commission %d and max royalty %d exceed 10000 basis points\n", config.SaleCommissionBasisPoints, config.MaxRoyaltyBasisPoints)
+	}
+	if config.CancelPenaltyBasisPoints > 10000 {
+		return fmt.Errorf("cancel penalty %d exceeds 10000 basis points\n", config.CancelPenaltyBasisPoints)
+	}
+	if config.SealedRevealLifeTime == 0 || config.SealedRevealLifeTime > config.MaxLifeTime {
+		return fmt.Errorf("sealed reveal life time must be in [1,%d] min\n", config.MaxLifeTime)
+	}
+	updateTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	config.Version++
+	config.UpdateTime = updateTime
+
+	key, err := ctx.GetStub().CreateCompositeKey(ConfigPrefix, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data %v", err)
+	}
+	err = ctx.GetStub().PutState(key, jvalue)
+	if err != nil {
+		return fmt.Errorf("failed to PutState %v\n", err)
+	}
+	return emitEvent(ctx, ConfigChangedEvent, config)
+}
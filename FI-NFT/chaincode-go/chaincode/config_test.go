@@ -0,0 +1,131 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestConfigDefaultsFieldsMissingFromStoredConfig(t *testing.T) {
+	l := newTestLedger(t)
+	ctx := l.adminCtx()
+	// a configuration stored before CancelPenaltyBasisPoints and SealedRevealLifeTime existed
+	key, err := ctx.GetStub().CreateCompositeKey(ConfigPrefix, []string{})
+	mustSucceed(t, err)
+	jvalue, err := json.Marshal(map[string]interface{}{
+		"Version":       1,
+		"AdminMSPIDs":   []string{"Org3MSP"},
+		"Treasury":      "fees",
+		"MaxLifeTime":   60,
+		"IPFSEndpoints": []string{"ipfs:5001"},
+	})
+	mustSucceed(t, err)
+	mustSucceed(t, ctx.GetStub().PutState(key, jvalue))
+
+	config, err := getConfig(l.ctx("reader"))
+	mustSucceed(t, err)
+	if config.CancelPenaltyBasisPoints != CANCEL_PENALTY_BASIS_POINTS || config.SealedRevealLifeTime != SEALED_REVEAL_LIFETIME {
+		t.Fatalf("new fields are %d and %d", config.CancelPenaltyBasisPoints, config.SealedRevealLifeTime)
+	}
+	if len(config.AdminMSPIDs) != 1 || config.AdminMSPIDs[0] != "Org3MSP" || config.Treasury != "fees" || config.MaxLifeTime != 60 {
+		t.Fatalf("stored fields are %+v", config)
+	}
+}
+
+func TestSetConfigValidatesAuctionSettings(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openTreasury()
+	config, err := s.GetConfig(l.ctx("reader"))
+	mustSucceed(t, err)
+
+	config.CancelPenaltyBasisPoints = 10001
+	_, err = s.SetConfig(l.adminCtx(), config)
+	mustFail(t, err, "cancel penalty 10001 exceeds 10000 basis points")
+	config.CancelPenaltyBasisPoints = 0
+	config.SealedRevealLifeTime = 0
+	_, err = s.SetConfig(l.adminCtx(), config)
+	mustFail(t, err, "sealed reveal life time")
+	config.SealedRevealLifeTime = config.MaxLifeTime + 1
+	_, err = s.SetConfig(l.adminCtx(), config)
+	mustFail(t, err, "sealed reveal life time")
+
+	config.SealedRevealLifeTime = 5
+	_, err = s.SetConfig(l.adminCtx(), config)
+	mustSucceed(t, err)
+	l.seedNFT("1", "seller")
+	bid, err := s.AddAuction(l.ctx("seller"), "1", AuctionSealedBid, 10, 0, 10)
+	mustSucceed(t, err)
+	if bid.RevealDeadline != bid.CommitDeadline+5*60*1000 {
+		t.Fatalf("reveal phase lasts %d ms", bid.RevealDeadline-bid.CommitDeadline)
+	}
+}
+
+func TestInitLedgerOnce(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	_, err := s.InitLedger(l.ctx("alice"), "treasury")
+	mustFail(t, err, "not authenticated")
+	_, err = s.InitLedger(l.adminCtx(), "")
+	mustFail(t, err, "empty treasury account")
+	_, err = s.InitLedger(l.adminCtx(), "treasury")
+	mustFail(t, err, "treasury account treasury is not open")
+	l.openAccounts(0, "treasury")
+	ctx := l.adminCtx()
+	config, err := s.InitLedger(ctx, "treasury")
+	mustSucceed(t, err)
+	if config.Version != 1 || config.Treasury != "treasury" || config.MintFee != MINT_FEE || config.MaxLifeTime != MAX_LIFETIME || config.UpdateTime != l.nowMillis() {
+		t.Fatalf("initial config is %+v", config)
+	}
+	if names := eventNames(ctx); len(names) != 1 || names[0] != ConfigChangedEvent {
+		t.Fatalf("events are %v", names)
+	}
+	_, err = s.InitLedger(l.adminCtx(), "treasury")
+	mustFail(t, err, "already initialized at version 1")
+}
+
+func TestSetConfigChecksVersion(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(0, "treasury")
+	_, err := s.InitLedger(l.adminCtx(), "treasury")
+	mustSucceed(t, err)
+	stale, err := s.GetConfig(l.ctx("reader"))
+	mustSucceed(t, err)
+	config, err := s.GetConfig(l.ctx("reader"))
+	mustSucceed(t, err)
+
+	_, err = s.SetConfig(l.ctx("alice"), config)
+	mustFail(t, err, "not authenticated")
+	config.MaxLifeTime = 90
+	config, err = s.SetConfig(l.adminCtx(), config)
+	mustSucceed(t, err)
+	if config.Version != 2 {
+		t.Fatalf("version after SetConfig is %d", config.Version)
+	}
+	stale.MintFee = 0
+	_, err = s.SetConfig(l.adminCtx(), stale)
+	mustFail(t, err, "config changed to version 2 since version 1")
+
+	l.seedNFT("1", "seller")
+	_, err = s.AddBid(l.ctx("seller"), "1", 10, 100, 91, 0, 0)
+	mustFail(t, err, "exceed max time(90 min)")
+}
+
+func TestSetConfigMovesAdminRights(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openTreasury()
+	config, err := s.GetConfig(l.ctx("reader"))
+	mustSucceed(t, err)
+	config.AdminMSPIDs = nil
+	_, err = s.SetConfig(l.adminCtx(), config)
+	mustFail(t, err, "no admin MSP")
+	config.AdminMSPIDs = []string{"Org3MSP"}
+	_, err = s.SetConfig(l.adminCtx(), config)
+	mustSucceed(t, err)
+
+	_, err = s.SetFees(l.adminCtx(), "treasury", 1, 0)
+	mustFail(t, err, "not authenticated")
+	_, err = s.SetFees(l.ctxOf("admin", "Org3MSP"), "treasury", 1, 0)
+	mustSucceed(t, err)
+}
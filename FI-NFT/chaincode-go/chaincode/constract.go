@@ -2,24 +2,48 @@ package chaincode
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
 	shell "github.com/ipfs/go-ipfs-api"
 )
 
+// AdmintMSPID, MINT_FEE, MAX_LIFETIME, SEALED_REVEAL_LIFETIME, CANCEL_PENALTY_BASIS_POINTS and
+// MAX_ROYALTY_BASIS_POINTS are the defaults of the on-chain Config.
 const AdmintMSPID = "Org1MSP"
 const NFTPrefix = "tokenID~CID~Oaccount"
 const BidPrefix = "tokenID~currentPrice~killPrice"
 const BalancePrefix = "account~balance"
+const EscrowPrefix = "tokenID~bidder~heldAmount"
+const ReservePrefix = "tokenID~reservePrice"
 
+// Transient map keys carrying the hidden reserve price of AddBid, RelistAuction and RevealReserve.
+const (
+	ReservePriceTransientKey = "reservePrice"
+	ReserveSaltTransientKey  = "salt"
+)
+
+// legacy whitespace-joined lists, only read by MigrateListIndexes
 const NFTBidListsPrefix = "tokenID~tokenID~~"
 const NFTListsPrefix = "account~tokenID~tokenID~~"
 
+const OwnerIndexPrefix = "owner~tokenID"
+const AuctionIndexPrefix = "auction~tokenID"
+
 const MINT_FEE = 10
 const MAX_LIFETIME = 3 * 24 * 60
+const MAX_PAGE_SIZE = 100
+const SEALED_REVEAL_LIFETIME = 60
+
+const CANCEL_PENALTY_BASIS_POINTS = 500
+const MAX_ROYALTY_BASIS_POINTS = 1000
 const NonBidder = "暂无竞拍"
 
 const ADDPREFIX = "fabric-uploader-local-file-"
@@ -35,20 +59,84 @@ type NFT struct {
 	CID      string
 	Owner    string
 	FileType string
+	// Approved is the single account allowed to transfer this token besides its owner, cleared on every transfer
+	Approved string
+	// Creator is the minter, paid RoyaltyBasisPoints (1/10000) of every sale by another seller. Both never change.
+	Creator            string
+	RoyaltyBasisPoints uint64
+	// Supply is the number of editions of a token class, 0 for a unique NFT. A class has no Owner,
+	// its holders are kept under EditionPrefix.
+	Supply uint64
+	// Size is the content length in bytes declared by the minter, 0 for tokens minted with MintWithFile
+	Size uint64
+	// CID is kept as a CIDv1 in base32, Codec and HashFunction are the multicodec names of its parts.
+	// All three are empty or in the form returned by IPFS for tokens minted before CIDs were parsed.
+	Codec        string
+	HashFunction string
+	// Verifier attested at VerifyTime that the content of CID is on IPFS, see AttestContent
+	Verifier   string
+	VerifyTime uint64
+	// MintTime is the time of the mint transaction, 0 for tokens minted before it was recorded
+	MintTime uint64
+	// Name, Description and Attributes are copied from the metadata JSON at MetadataCID, see MintWithMetadata.
+	// All four are left out for tokens minted without metadata.
+	Name        string         `json:",omitempty" metadata:",optional"`
+	Description string         `json:",omitempty" metadata:",optional"`
+	Attributes  []NFTAttribute `json:",omitempty" metadata:",optional"`
+	MetadataCID string         `json:",omitempty" metadata:",optional"`
 }
 type NFTBid struct {
-	TokenID      string
+	// TokenID is the NFT sold, or the lot ID of an edition lot, see editionLotID
+	TokenID string
+	// ClassID is the token class of an edition lot, empty for a unique NFT
+	ClassID string `json:",omitempty" metadata:",optional"`
+	// Seller listed the auction, empty for auctions listed before it was recorded: the NFT owner
+	Seller string
+	// Quantity is the number of editions of a token class sold as one lot, 0 for a unique NFT
+	Quantity uint64
+	// AuctionType selects the auctionFormat handling offers and settlement, empty for English
+	AuctionType  string
 	CurrentPrice uint64
 	CurrentOwner string
+	LowerPrice   uint64
 	KillPrice    uint64
 	CreateTime   uint64
 	LifeTime     uint64
+	// CommitDeadline and RevealDeadline end the two phases of a sealed-bid auction
+	CommitDeadline uint64
+	RevealDeadline uint64
+	// a bid within SoftCloseWindow of the end extends LifeTime by SoftCloseExtension, until Extended reaches MaxExtension
+	SoftCloseWindow    uint64
+	SoftCloseExtension uint64
+	MaxExtension       uint64
+	Extended           uint64
+	// an English bid must beat CurrentPrice by the larger of MinIncrement and MinIncrementBasisPoints of CurrentPrice
+	MinIncrement            uint64
+	MinIncrementBasisPoints uint64
 }
 type AccountBalance struct {
 	Account string
 	Balance uint64
 }
 
+// ReservePrice is the hidden reserve price of an English auction. Only Hash, see ReservePriceHash, is stored
+// until the seller reveals Price with RevealReserve once the auction has ended.
+type ReservePrice struct {
+	TokenID  string
+	Hash     string
+	Revealed bool
+	Price    uint64
+}
+
+// BidEscrow holds the funds reserved by the current top bidder of an auction.
+// The amount is taken out of the bidder's available balance when the bid is placed
+// and is either refunded when outbid or paid to the seller when the auction ends.
+type BidEscrow struct {
+	TokenID string
+	Bidder  string
+	Amount  uint64
+}
+
 func (s *SmartContract) ClientAccountID(ctx contractapi.TransactionContextInterface) (string, error) {
 
 	// Get ID of submitting client identity
@@ -60,40 +148,53 @@ func (s *SmartContract) ClientAccountID(ctx contractapi.TransactionContextInterf
 	return clientAccountID, nil
 }
 
+// Offer places a bid of Price on the auction of tokenID, how the offer is judged depends on the auction type.
 func (s *SmartContract) Offer(ctx contractapi.TransactionContextInterface, Price uint64, tokenID string) error {
+	_, err := placeBid(ctx, tokenID, Price)
+	if err != nil {
+		return fmt.Errorf("failed to Offer: %v\n", err)
+	}
+	return nil
+}
+
+// placeBid is the single bid path behind Offer and UpdateBid, the auction format of tokenID judges the price.
+func placeBid(ctx contractapi.TransactionContextInterface, tokenID string, price uint64) (*NFTBid, error) {
 	operator, _ := ctx.GetClientIdentity().GetID()
 
-	ab, err := getAccountBalance(ctx, operator)
+	bid, err := getBid(ctx, tokenID)
 	if err != nil {
-		return fmt.Errorf("failed to getAccountBalance for Offer: %v\n", err)
+		return nil, fmt.Errorf("failed to getBid: %v\n", err)
 	}
-	if ab.Balance < Price {
-		return fmt.Errorf("failed to Offer, no enough balance, has: %d, offer: %d\n", ab.Balance, Price)
+	format, err := getAuctionFormat(bid)
+	if err != nil {
+		return nil, err
 	}
-
-	bid, err := getBid(ctx, tokenID)
+	currentTime, err := getTxTime(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to getBid for Offer: %v\n", err)
+		return nil, err
 	}
-
-	if Price < bid.CurrentPrice {
-		return fmt.Errorf("failed to Offer, price lower than current max price\n")
+	if format.ended(bid, currentTime) {
+		return nil, fmt.Errorf("auction has ended\n")
+	}
+	nft, err := getNFT(ctx, auctionedToken(bid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to getNFT: %v\n", err)
+	}
+	if auctionSeller(nft, bid) == operator {
+		return nil, fmt.Errorf("owner cannot bid\n")
 	}
 
-	bid.CurrentPrice = Price
-	bid.CurrentOwner = operator
-	key, _ := ctx.GetStub().CreateCompositeKey(BidPrefix, []string{tokenID})
-	jvalue, err := json.Marshal(bid)
+	err = format.offer(ctx, bid, operator, price, currentTime)
 	if err != nil {
-		return fmt.Errorf("failed to marshal json data for Offer: %v\n")
+		return nil, err
 	}
-	err = ctx.GetStub().PutState(key, jvalue)
+	err = putBid(ctx, bid)
 	if err != nil {
-		return fmt.Errorf("failed to PutState for Offer: %v\n", err)
+		return nil, fmt.Errorf("failed to PutState: %v\n", err)
 	}
-	return nil
+	return bid, nil
 }
-func (s *SmartContract) FindBidToEnd(ctx contractapi.TransactionContextInterface, currentTime uint64) error {
+func (s *SmartContract) FindBidToEnd(ctx contractapi.TransactionContextInterface) error {
 	tokenIDs, err := getBidsList(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get bid by index %v\n", err)
@@ -101,83 +202,135 @@ func (s *SmartContract) FindBidToEnd(ctx contractapi.TransactionContextInterface
 
 	//first, check bidList: end timeout bids
 	for i := 0; i < len(tokenIDs); i++ {
-		err := tryEndBid(ctx, tokenIDs[i], currentTime)
+		err := tryEndBid(ctx, tokenIDs[i])
 		if err != nil {
 			return fmt.Errorf("failed to tryEndBid for FindBidToEnd: %v\n", err)
 		}
 	}
 	return nil
 }
-func (s *SmartContract) TryEndBid(ctx contractapi.TransactionContextInterface, tokenID string, currentTime uint64) error {
-	return tryEndBid(ctx, tokenID, currentTime)
+func (s *SmartContract) TryEndBid(ctx contractapi.TransactionContextInterface, tokenID string) error {
+	return tryEndBid(ctx, tokenID)
 }
-func tryEndBid(ctx contractapi.TransactionContextInterface, tokenID string, currentTime uint64) error {
+func tryEndBid(ctx contractapi.TransactionContextInterface, tokenID string) error {
 	bid, err := getBid(ctx, tokenID)
 	if err != nil {
 		return fmt.Errorf("failed to getBid for TryEndBid: %v\n", err)
 	}
-	if currentTime-bid.CreateTime > bid.LifeTime || bid.CurrentPrice >= bid.KillPrice {
-		err := endBid(ctx, tokenID, bid.CurrentPrice)
-		if err != nil {
-			return fmt.Errorf("failed to endBid for TryEndBidv: %v\n", err)
-		}
+	currentTime, err := getTxTime(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to getTxTime for TryEndBid: %v\n", err)
+	}
+	format, err := getAuctionFormat(bid)
+	if err != nil {
+		return fmt.Errorf("failed to TryEndBid: %v\n", err)
+	}
+	if !format.ended(bid, currentTime) {
+		return nil
+	}
+	pending, err := reservePending(ctx, bid, currentTime)
+	if err != nil {
+		return fmt.Errorf("failed to check reserve price for TryEndBid: %v\n", err)
+	}
+	if pending {
+		return nil
+	}
+	err = endBid(ctx, bid)
+	if err != nil {
+		return fmt.Errorf("failed to endBid for TryEndBidv: %v\n", err)
 	}
 	return nil
 }
 
-func (s *SmartContract) AddBid(ctx contractapi.TransactionContextInterface, tokenID string, lowerPrice uint64, upPrice uint64, createTime uint64, lifeMinute uint64) (*NFTBid, error) {
-	exists, _ := bidExists(ctx, tokenID)
-	if exists {
-		return nil, fmt.Errorf("Bid already exists\n")
+// AddBid puts tokenID on an English auction, see AddAuction. Every bid after the first must raise the price
+// by at least minIncrement and by at least minIncrementBasisPoints (1/10000) of the current price.
+// A hidden reserve price, between lowerPrice and upPrice, can be passed in the transient map with a non-empty salt,
+// only their hash is stored, see ReservePriceHash. If the top bid is below it when the auction ends, the bidder is refunded
+// and the token is not sold.
+func (s *SmartContract) AddBid(ctx contractapi.TransactionContextInterface, tokenID string, lowerPrice uint64, upPrice uint64, lifeMinute uint64, minIncrement uint64, minIncrementBasisPoints uint64) (*NFTBid, error) {
+	if minIncrementBasisPoints > 10000 {
+		return nil, fmt.Errorf("failed to AddBid, increment %d basis points exceeds 10000\n", minIncrementBasisPoints)
+	}
+	reserve, err := reservePriceFromTransient(ctx, tokenID, lowerPrice, upPrice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to AddBid: %v\n", err)
 	}
-	if lifeMinute > MAX_LIFETIME {
-		return nil, fmt.Errorf("failed to AddBid, life time exceed max time(%d min)\n", MAX_LIFETIME)
+	bid, err := addAuction(ctx, tokenID, AuctionEnglish, lowerPrice, upPrice, lifeMinute, 0)
+	if err != nil {
+		return nil, err
+	}
+	bid.MinIncrement = minIncrement
+	bid.MinIncrementBasisPoints = minIncrementBasisPoints
+	err = putBid(ctx, bid)
+	if err != nil {
+		return nil, fmt.Errorf("falied to add new Bid %v\n", err)
 	}
-	// check operator==NFT.Owner
+	if reserve != nil {
+		err = putReservePrice(ctx, reserve)
+		if err != nil {
+			return nil, fmt.Errorf("failed to put reserve price for AddBid: %v\n", err)
+		}
+	}
+	return bid, nil
+}
+
+// RevealReserve opens the hidden reserve price of the English auction of tokenID, with the same reserve price
+// and salt in the transient map as AddBid. Only the seller can reveal, once the auction has ended with a bid below its
+// kill price.
+// The auction is settled by the next TryEndBid or FindBidToEnd against the revealed price; a reserve price
+// that is not revealed within Config.SealedRevealLifeTime minutes of the end counts as met.
+func (s *SmartContract) RevealReserve(ctx contractapi.TransactionContextInterface, tokenID string) (*ReservePrice, error) {
 	operator, err := ctx.GetClientIdentity().GetID()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get client id: %v", err)
 	}
-	nft, err := getNFT(ctx, tokenID)
+	bid, err := getBid(ctx, tokenID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get nft %v\n", err)
+		return nil, fmt.Errorf("failed to getBid for RevealReserve: %v\n", err)
 	}
-	if nft.Owner != operator {
-		return nil, fmt.Errorf("failed to AddBid, not Owner\n")
+	nft, err := getNFT(ctx, auctionedToken(bid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to getNFT for RevealReserve: %v\n", err)
 	}
-
-	life := lifeMinute * 60 * 1000
-
-	fmt.Printf("%v AddBid, with lifeTime %v\n", createTime, life)
-	newbid := &NFTBid{
-		TokenID:      tokenID,
-		CurrentPrice: lowerPrice,
-		CurrentOwner: NonBidder,
-		KillPrice:    upPrice,
-		CreateTime:   createTime,
-		LifeTime:     life,
+	if auctionSeller(nft, bid) != operator {
+		return nil, fmt.Errorf("failed to RevealReserve, not Owner\n")
 	}
-	key, err := ctx.GetStub().CreateCompositeKey(BidPrefix, []string{tokenID})
+	currentTime, err := getTxTime(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create composite key %v\n", err)
+		return nil, fmt.Errorf("failed to getTxTime for RevealReserve: %v\n", err)
 	}
-
-	jvalue, err := json.Marshal(newbid)
+	format, err := getAuctionFormat(bid)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal data\n")
+		return nil, fmt.Errorf("failed to RevealReserve: %v\n", err)
 	}
-	err = ctx.GetStub().PutState(key, jvalue)
-	fmt.Printf("AddBid {%s : %v}\n", key, newbid)
+	if !format.ended(bid, currentTime) {
+		return nil, fmt.Errorf("failed to RevealReserve, auction is still running\n")
+	}
+	pending, err := reservePending(ctx, bid, currentTime)
 	if err != nil {
-		return nil, fmt.Errorf("falied to add new Bid %v\n", err)
+		return nil, fmt.Errorf("failed to check reserve price for RevealReserve: %v\n", err)
 	}
-
-	err = addBidsToList(ctx, tokenID)
+	if !pending {
+		return nil, fmt.Errorf("failed to RevealReserve, no reserve price waiting to be revealed\n")
+	}
+	reserve, err := getReservePrice(ctx, tokenID)
 	if err != nil {
-		fmt.Println(err)
-		return nil, fmt.Errorf("failed to add new bid to list %v\n", err)
+		return nil, fmt.Errorf("failed to get reserve price for RevealReserve: %v\n", err)
 	}
-	return newbid, nil
+	price, salt, found, err := getReservePriceTransient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to RevealReserve: %v\n", err)
+	}
+	if !found || ReservePriceHash(tokenID, price, salt) != reserve.Hash {
+		return nil, fmt.Errorf("failed to RevealReserve, reserve price and salt do not match the hash\n")
+	}
+	reserve.Revealed = true
+	reserve.Price = price
+	err = putReservePrice(ctx, reserve)
+	if err != nil {
+		return nil, fmt.Errorf("failed to PutState for RevealReserve: %v\n", err)
+	}
+	return reserve, nil
 }
 
 func (s *SmartContract) GetBidByIndex(ctx contractapi.TransactionContextInterface, index uint64) (*NFTBid, error) {
@@ -196,34 +349,152 @@ func (s *SmartContract) GetBidByIndex(ctx contractapi.TransactionContextInterfac
 	return bid, nil
 }
 
+// UpdateBid raises the price of an English auction, newPrice must be higher than the current price.
+// UpdateBid is Offer returning the updated NFTBid.
 func (s *SmartContract) UpdateBid(ctx contractapi.TransactionContextInterface, tokenID string, newPrice uint64) (*NFTBid, error) {
-	operator, _ := ctx.GetClientIdentity().GetID()
-	ab, err := getAccountBalance(ctx, operator)
+	bid, err := placeBid(ctx, tokenID, newPrice)
 	if err != nil {
-		return nil, fmt.Errorf("failed to getAccountBalance for UpdateBid: %v\n", err)
+		return nil, fmt.Errorf("failed to UpdateBid: %v\n", err)
 	}
-	if ab.Balance < newPrice {
-		return nil, fmt.Errorf("no enough balance for bid, remaining: %d, offer: %d\n", ab.Balance, newPrice)
+	return bid, nil
+}
+
+// CancelAuction withdraws a running auction of tokenID, only its seller can cancel.
+// Without a bid the auction is simply removed. With a bid, the funds held for the top bidder are refunded
+// and the seller pays the bidder Config.CancelPenaltyBasisPoints of the bid as compensation.
+// An auction that has already timed out or reached its kill price must be settled with TryEndBid instead.
+// A sealed-bid auction can only be cancelled during its commit phase, and every deposit is refunded.
+// Editions listed with AddEditionAuction go back to the seller.
+func (s *SmartContract) CancelAuction(ctx contractapi.TransactionContextInterface, tokenID string) error {
+	operator, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+	bid, err := getBid(ctx, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to getBid for CancelAuction: %v\n", err)
+	}
+	nft, err := getNFT(ctx, auctionedToken(bid))
+	if err != nil {
+		return fmt.Errorf("failed to getNFT for CancelAuction: %v\n", err)
+	}
+	if auctionSeller(nft, bid) != operator {
+		return fmt.Errorf("failed to CancelAuction, not Owner\n")
+	}
+	currentTime, err := getTxTime(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to getTxTime for CancelAuction: %v\n", err)
 	}
 
+	format, err := getAuctionFormat(bid)
+	if err != nil {
+		return fmt.Errorf("failed to CancelAuction: %v\n", err)
+	}
+	reason, err := format.cancel(ctx, bid, currentTime)
+	if err != nil {
+		return fmt.Errorf("failed to CancelAuction: %v\n", err)
+	}
+
+	if bid.Quantity > 0 {
+		err = creditEditions(ctx, nft.ID, operator, bid.Quantity)
+		if err != nil {
+			return fmt.Errorf("failed to return editions for CancelAuction: %v\n", err)
+		}
+	}
+	err = deleteBid(ctx, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to deleteBid for CancelAuction: %v\n", err)
+	}
+	err = removeBidFromList(ctx, tokenID)
+	if err != nil {
+		return err
+	}
+	return emitEvent(ctx, AuctionCancelledEvent, &AuctionCancelled{TokenID: tokenID, Seller: operator, Reason: reason})
+}
+
+// RelistAuction restarts an auction that ended without any bid, with new prices and life time,
+// in place of ending it and calling AddBid again. The reserve price of an English auction is replaced
+// by the one in the transient map, as with AddBid, or dropped if there is none.
+func (s *SmartContract) RelistAuction(ctx contractapi.TransactionContextInterface, tokenID string, lowerPrice uint64, upPrice uint64, lifeMinute uint64) (*NFTBid, error) {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getConfig for RelistAuction: %v\n", err)
+	}
+	if lifeMinute > config.MaxLifeTime {
+		return nil, fmt.Errorf("failed to RelistAuction, life time exceed max time(%d min)\n", config.MaxLifeTime)
+	}
+	operator, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client id: %v", err)
+	}
 	bid, err := getBid(ctx, tokenID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to getBid for UpdateBid: %v\n", err)
+		return nil, fmt.Errorf("failed to getBid for RelistAuction: %v\n", err)
+	}
+	nft, err := getNFT(ctx, auctionedToken(bid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to getNFT for RelistAuction: %v\n", err)
 	}
-	if newPrice <= bid.CurrentPrice {
-		return nil, fmt.Errorf("failed to UpdateBid, not offer higher price\n")
+	if auctionSeller(nft, bid) != operator {
+		return nil, fmt.Errorf("failed to RelistAuction, not Owner\n")
+	}
+	currentTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getTxTime for RelistAuction: %v\n", err)
+	}
+	format, err := getAuctionFormat(bid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to RelistAuction: %v\n", err)
+	}
+	if !format.ended(bid, currentTime) {
+		return nil, fmt.Errorf("failed to RelistAuction, auction is still running\n")
+	}
+	hasBids, err := format.hasBids(ctx, bid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to RelistAuction: %v\n", err)
+	}
+	if hasBids {
+		return nil, fmt.Errorf("failed to RelistAuction, auction has bids and must be settled\n")
 	}
-	bid.CurrentPrice = newPrice
-	bid.CurrentOwner = operator
 
-	value, err := json.Marshal(bid)
+	bid.CreateTime = currentTime
+	bid.LifeTime = lifeMinute * 60 * 1000
+	bid.Extended = 0
+	err = format.open(bid, lowerPrice, upPrice, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to RelistAuction: %v\n", err)
+	}
+	if bid.AuctionType == "" || bid.AuctionType == AuctionEnglish {
+		reserve, err := reservePriceFromTransient(ctx, tokenID, bid.LowerPrice, bid.KillPrice)
+		if err != nil {
+			return nil, fmt.Errorf("failed to RelistAuction: %v\n", err)
+		}
+		err = deleteReservePrice(ctx, tokenID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete reserve price for RelistAuction: %v\n", err)
+		}
+		if reserve != nil {
+			err = putReservePrice(ctx, reserve)
+			if err != nil {
+				return nil, fmt.Errorf("failed to put reserve price for RelistAuction: %v\n", err)
+			}
+		}
+	}
+	err = putBid(ctx, bid)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal bid for UpdateBid: %v\n", err)
+		return nil, fmt.Errorf("failed to PutState for RelistAuction: %v\n", err)
 	}
-	key, _ := ctx.GetStub().CreateCompositeKey(BidPrefix, []string{tokenID})
-	err = ctx.GetStub().PutState(key, value)
+	err = emitEvent(ctx, AuctionRelistedEvent, &AuctionCreated{
+		TokenID:     tokenID,
+		AuctionType: bid.AuctionType,
+		Seller:      operator,
+		LowerPrice:  bid.LowerPrice,
+		KillPrice:   bid.KillPrice,
+		CreateTime:  currentTime,
+		LifeTime:    bid.LifeTime,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to PutState for UpdateBid: %v\n", err)
+		return nil, err
 	}
 	return bid, nil
 }
@@ -233,7 +504,210 @@ func deleteBid(ctx contractapi.TransactionContextInterface, tokenID string) erro
 	if !exists {
 		return fmt.Errorf("failed to DeleteBid, bid not exist\n")
 	}
-	return ctx.GetStub().DelState(tokenID)
+	key, err := ctx.GetStub().CreateCompositeKey(BidPrefix, []string{tokenID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return err
+	}
+	return deleteReservePrice(ctx, tokenID)
+}
+
+// holdBidFunds reserves price from bidder's available balance for bid and
+// refunds whatever was held for the previous top bidder.
+func holdBidFunds(ctx contractapi.TransactionContextInterface, bid *NFTBid, bidder string, price uint64) error {
+	var held uint64
+	if bid.CurrentOwner != NonBidder {
+		escrow, err := getEscrow(ctx, bid.TokenID)
+		if err != nil {
+			return fmt.Errorf("failed to getEscrow for holdBidFunds: %v\n", err)
+		}
+		if escrow.Bidder == bidder {
+			//raising own bid, only the difference needs to be held
+			held = escrow.Amount
+		} else {
+			_, err = credit(ctx, escrow.Bidder, escrow.Amount)
+			if err != nil {
+				return fmt.Errorf("failed to refund outbid bidder: %v\n", err)
+			}
+		}
+	}
+	if price < held {
+		return fmt.Errorf("failed to holdBidFunds, new price %d lower than held %d\n", price, held)
+	}
+
+	_, err := debit(ctx, bidder, price-held)
+	if err != nil {
+		return fmt.Errorf("failed to take out price from bidder: %v\n", err)
+	}
+
+	return putEscrow(ctx, &BidEscrow{
+		TokenID: bid.TokenID,
+		Bidder:  bidder,
+		Amount:  price,
+	})
+}
+
+// emitBidPlaced raises BidPlaced for a new price on bid, before bid is updated to the new top offer.
+func emitBidPlaced(ctx contractapi.TransactionContextInterface, bid *NFTBid, bidder string, price uint64) error {
+	event := &BidPlaced{
+		TokenID:        bid.TokenID,
+		Bidder:         bidder,
+		Price:          price,
+		PreviousBidder: bid.CurrentOwner,
+	}
+	if bid.CurrentOwner != NonBidder {
+		event.PreviousPrice = bid.CurrentPrice
+	}
+	return emitEvent(ctx, BidPlacedEvent, event)
+}
+
+func getEscrow(ctx contractapi.TransactionContextInterface, tokenID string) (*BidEscrow, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(EscrowPrefix, []string{tokenID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+	}
+	if len(jvalue) == 0 {
+		return nil, fmt.Errorf("Escrow not exist\n")
+	}
+	value := &BidEscrow{}
+	err = json.Unmarshal(jvalue, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data %v", err)
+	}
+	return value, nil
+}
+
+func putEscrow(ctx contractapi.TransactionContextInterface, escrow *BidEscrow) error {
+	key, err := ctx.GetStub().CreateCompositeKey(EscrowPrefix, []string{escrow.TokenID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := json.Marshal(escrow)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data %v", err)
+	}
+	return ctx.GetStub().PutState(key, jvalue)
+}
+
+func deleteEscrow(ctx contractapi.TransactionContextInterface, tokenID string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(EscrowPrefix, []string{tokenID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	return ctx.GetStub().DelState(key)
+}
+
+// ReservePriceHash is the hash stored for a reserve price of reservePrice on the auction of tokenID:
+// hex(sha256("<tokenID>:<reservePrice>:<salt>")).
+func ReservePriceHash(tokenID string, reservePrice uint64, salt string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%s", tokenID, reservePrice, salt)))
+	return hex.EncodeToString(sum[:])
+}
+
+// reservePriceFromTransient returns the reserve price passed in the transient map for an auction of tokenID from
+// lowerPrice to upPrice, nil if there is none. The price must lie between the two and the salt must not be empty,
+// only their hash is kept.
+func reservePriceFromTransient(ctx contractapi.TransactionContextInterface, tokenID string, lowerPrice uint64, upPrice uint64) (*ReservePrice, error) {
+	price, salt, found, err := getReservePriceTransient(ctx)
+	if err != nil || !found {
+		return nil, err
+	}
+	if price < lowerPrice || price > upPrice {
+		return nil, fmt.Errorf("reserve price must be between %d and %d\n", lowerPrice, upPrice)
+	}
+	return &ReservePrice{TokenID: tokenID, Hash: ReservePriceHash(tokenID, price, salt)}, nil
+}
+
+// reservePending reports whether the English auction bid, ended at currentTime, waits for its seller to reveal
+// the reserve price: it timed out below its kill price with a bid, and the reveal phase is not over.
+func reservePending(ctx contractapi.TransactionContextInterface, bid *NFTBid, currentTime uint64) (bool, error) {
+	if bid.AuctionType != "" && bid.AuctionType != AuctionEnglish {
+		return false, nil
+	}
+	if bid.CurrentOwner == NonBidder || bid.CurrentPrice >= bid.KillPrice {
+		return false, nil
+	}
+	reserve, err := getReservePrice(ctx, bid.TokenID)
+	if err != nil || reserve == nil || reserve.Revealed {
+		return false, err
+	}
+	config, err := getConfig(ctx)
+	if err != nil {
+		return false, err
+	}
+	return currentTime <= bid.CreateTime+bid.LifeTime+config.SealedRevealLifeTime*60*1000, nil
+}
+
+func getReservePriceTransient(ctx contractapi.TransactionContextInterface) (uint64, string, bool, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return 0, "", false, fmt.Errorf("failed to get transient: %v\n", err)
+	}
+	priceValue, ok := transient[ReservePriceTransientKey]
+	if !ok {
+		return 0, "", false, nil
+	}
+	price, err := strconv.ParseUint(string(priceValue), 10, 64)
+	if err != nil {
+		return 0, "", false, fmt.Errorf("invalid %s in transient map: %v\n", ReservePriceTransientKey, err)
+	}
+	salt, ok := transient[ReserveSaltTransientKey]
+	if !ok || len(salt) == 0 {
+		return 0, "", false, fmt.Errorf("%s not in transient map\n", ReserveSaltTransientKey)
+	}
+	return price, string(salt), true, nil
+}
+
+// getReservePrice returns the reserve price of the auction of tokenID, nil if it has none.
+func getReservePrice(ctx contractapi.TransactionContextInterface, tokenID string) (*ReservePrice, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(ReservePrefix, []string{tokenID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+	}
+	if len(jvalue) == 0 {
+		return nil, nil
+	}
+	value := &ReservePrice{}
+	err = json.Unmarshal(jvalue, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data %v", err)
+	}
+	return value, nil
+}
+
+func putReservePrice(ctx contractapi.TransactionContextInterface, reserve *ReservePrice) error {
+	key, err := ctx.GetStub().CreateCompositeKey(ReservePrefix, []string{reserve.TokenID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := json.Marshal(reserve)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data %v", err)
+	}
+	return ctx.GetStub().PutState(key, jvalue)
+}
+
+func deleteReservePrice(ctx contractapi.TransactionContextInterface, tokenID string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(ReservePrefix, []string{tokenID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	return ctx.GetStub().DelState(key)
+}
+
+func (s *SmartContract) GetEscrow(ctx contractapi.TransactionContextInterface, tokenID string) (*BidEscrow, error) {
+	return getEscrow(ctx, tokenID)
 }
 
 func (s *SmartContract) GetAccountBalance(ctx contractapi.TransactionContextInterface) (*AccountBalance, error) {
@@ -241,59 +715,85 @@ func (s *SmartContract) GetAccountBalance(ctx contractapi.TransactionContextInte
 	return getAccountBalance(ctx, account)
 }
 
-//end bid with offer
+//end bid with the winner chosen by its auction format
 //if no bidder, simply remove bid
-func endBid(ctx contractapi.TransactionContextInterface, tokenID string, offer uint64) error {
-	bid, err := getBid(ctx, tokenID)
+func endBid(ctx contractapi.TransactionContextInterface, bid *NFTBid) error {
+	tokenID := bid.TokenID
+	nft, err := getNFT(ctx, auctionedToken(bid))
 	if err != nil {
-		return fmt.Errorf("failed to getBid for BidEnd: %v\n", err)
+		return fmt.Errorf("failed to getBFT for BidEnd: %v\n", err)
 	}
-	nft, err := getNFT(ctx, tokenID)
+	format, err := getAuctionFormat(bid)
 	if err != nil {
-		return fmt.Errorf("failed to getBFT for BidEnd: %v\n", err)
+		return fmt.Errorf("failed to BidEnd: %v\n", err)
 	}
-
-	newOwner := bid.CurrentOwner
-	oldOwner := nft.Owner
-	if newOwner != NonBidder {
-		//transfer balance from bid.CurrentOwner to nft.Owner
-		newOwnerAccount, err := getAccountBalance(ctx, newOwner)
-		if err != nil {
-			return fmt.Errorf("failed to getAccountBalance for BidEnd: %v\n", err)
-		}
-		if newOwnerAccount.Balance < offer {
-			return fmt.Errorf("failed to BidEnd, bidder cannot pay the price\n")
-		}
-		_, err = updateAccountBalance(ctx, newOwner, -1*int(offer))
+	oldOwner := auctionSeller(nft, bid)
+	result, err := format.settle(ctx, bid, oldOwner)
+	if err != nil {
+		return fmt.Errorf("failed to settle for BidEnd: %v\n", err)
+	}
+	newOwner := result.Winner
+	//pay the creator its royalty, the treasury its commission, and the seller the rest of the price
+	//and any forfeited deposits, in one update
+	creator, royalty := royaltyOf(nft, oldOwner, result.Price)
+	if royalty > 0 {
+		_, err = credit(ctx, creator, royalty)
 		if err != nil {
-			return fmt.Errorf("failed to take out price from bidder: %v\n", err)
+			return fmt.Errorf("failed to pay royalty to creator: %v\n", err)
 		}
-		_, err = updateAccountBalance(ctx, oldOwner, int(offer))
+	}
+	config, err := getConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to getConfig for BidEnd: %v\n", err)
+	}
+	commission := shareOf(result.Price, config.SaleCommissionBasisPoints)
+	if commission > result.Price-royalty {
+		//royalties minted under an older, higher cap come first
+		commission = result.Price - royalty
+	}
+	err = creditTreasury(ctx, config, commission)
+	if err != nil {
+		return fmt.Errorf("failed to credit commission for BidEnd: %v\n", err)
+	}
+	if result.Price-royalty-commission+result.Forfeited > 0 {
+		_, err = credit(ctx, oldOwner, result.Price-royalty-commission+result.Forfeited)
 		if err != nil {
 			return fmt.Errorf("failed to put in price into owner: %v\n", err)
 		}
-		//change nft owner
-		//add to new owner's list
-		err = addNFTToList(ctx, newOwner, tokenID)
-		if err != nil {
-			return fmt.Errorf("failed to add nft to new owner's list for endbid:%v\n", err)
+	}
+	if newOwner != NonBidder {
+		//change nft owner, or hand the editions of the lot to the winner
+		if bid.Quantity > 0 {
+			err = creditEditions(ctx, nft.ID, newOwner, bid.Quantity)
+			if err != nil {
+				return fmt.Errorf("failed to creditEditions for BidEnd: %v\n", err)
+			}
+		} else {
+			err = transferNFT(ctx, nft, newOwner)
+			if err != nil {
+				return fmt.Errorf("failed to transferNFT for BidEnd: %v\n", err)
+			}
 		}
-		//remove for old owner's list
-		err = removeNFTFromList(ctx, tokenID, oldOwner)
+
+		err = emitEvent(ctx, AuctionSettledEvent, &AuctionSettled{TokenID: tokenID, ClassID: bid.ClassID, Seller: oldOwner, Winner: newOwner, Price: result.Price, Royalty: royalty, Commission: commission, Quantity: bid.Quantity})
 		if err != nil {
-			return fmt.Errorf("failed to remove nft to old owner's list for endbid:%v\n", err)
+			return err
 		}
-		nft.Owner = newOwner
-		value, err := json.Marshal(nft)
-		if err != nil {
-			return fmt.Errorf("failed to marshal data for BidEnd: %v\n", err)
+	} else {
+		if bid.Quantity > 0 {
+			err = creditEditions(ctx, nft.ID, oldOwner, bid.Quantity)
+			if err != nil {
+				return fmt.Errorf("failed to return editions for BidEnd: %v\n", err)
+			}
+		}
+		reason := result.Reason
+		if reason == "" {
+			reason = "expired without bids"
 		}
-		key, _ := ctx.GetStub().CreateCompositeKey(NFTPrefix, []string{tokenID})
-		err = ctx.GetStub().PutState(key, value)
+		err = emitEvent(ctx, AuctionCancelledEvent, &AuctionCancelled{TokenID: tokenID, Seller: oldOwner, Reason: reason})
 		if err != nil {
-			return fmt.Errorf("failed to PutState for BidEnd: %v\n", err)
+			return err
 		}
-
 	}
 	//clean bid
 	err = deleteBid(ctx, tokenID)
@@ -307,22 +807,62 @@ func endBid(ctx contractapi.TransactionContextInterface, tokenID string, offer u
 	return nil
 }
 
-func (s *SmartContract) CanBidEnd(ctx contractapi.TransactionContextInterface, tokenID string, currentTime uint64) (bool, error) {
+type CanBidEndResult struct {
+	CanEnd   bool
+	EndTime  uint64 // CreateTime + LifeTime, including any soft-close extension, or the RevealDeadline of a sealed-bid auction
+	Extended uint64
+}
+
+func (s *SmartContract) CanBidEnd(ctx contractapi.TransactionContextInterface, tokenID string) (*CanBidEndResult, error) {
 	exists, err := bidExists(ctx, tokenID)
 	if err != nil {
-		return false, fmt.Errorf("faled to check bid exists for IsBidTimeout: %v\n", err)
+		return nil, fmt.Errorf("faled to check bid exists for IsBidTimeout: %v\n", err)
 	}
 	if !exists {
-		return false, fmt.Errorf("cannot end bid, bid not exist\n")
+		return nil, fmt.Errorf("cannot end bid, bid not exist\n")
 	}
 	bid, err := getBid(ctx, tokenID)
 	if err != nil {
-		return false, fmt.Errorf("failed to get bid for CanBidEnd: %v \n", err)
+		return nil, fmt.Errorf("failed to get bid for CanBidEnd: %v \n", err)
 	}
-	if currentTime-bid.CreateTime > bid.LifeTime {
-		return true, nil
+	currentTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getTxTime for CanBidEnd: %v\n", err)
+	}
+	format, err := getAuctionFormat(bid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to CanBidEnd: %v\n", err)
+	}
+	pending, err := reservePending(ctx, bid, currentTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check reserve price for CanBidEnd: %v\n", err)
 	}
-	return false, nil
+	result := &CanBidEndResult{
+		CanEnd:   format.ended(bid, currentTime) && !pending,
+		EndTime:  bid.CreateTime + bid.LifeTime,
+		Extended: bid.Extended,
+	}
+	if bid.AuctionType == AuctionSealedBid {
+		result.EndTime = bid.RevealDeadline
+	}
+	return result, nil
+}
+
+// getTxTime returns the transaction timestamp in milliseconds, the same unit as NFTBid.CreateTime and NFTBid.LifeTime.
+// All endorsing peers see the same value, unlike a time argument supplied by the client.
+func getTxTime(ctx contractapi.TransactionContextInterface) (uint64, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get tx timestamp: %v\n", err)
+	}
+	if ts.Seconds < 0 {
+		return 0, fmt.Errorf("invalid tx timestamp: %v\n", ts)
+	}
+	return uint64(ts.Seconds)*1000 + uint64(ts.Nanos)/1000000, nil
+}
+
+func bidTimedOut(bid *NFTBid, currentTime uint64) bool {
+	return currentTime > bid.CreateTime && currentTime-bid.CreateTime > bid.LifeTime
 }
 
 func (s *SmartContract) IsNFTOnSale(ctx contractapi.TransactionContextInterface, tokenID string) (bool, error) {
@@ -342,11 +882,15 @@ type TotalBidsWithTimeOutCheckResult struct {
 	HasTimeOutBid bool
 }
 
-func (s *SmartContract) TotalBidsWithTimeOutCheck(ctx contractapi.TransactionContextInterface, currentTime uint64) (*TotalBidsWithTimeOutCheckResult, error) {
+func (s *SmartContract) TotalBidsWithTimeOutCheck(ctx contractapi.TransactionContextInterface) (*TotalBidsWithTimeOutCheckResult, error) {
 	tokenIDs, err := getBidsList(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get bid by index %v\n", err)
 	}
+	currentTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getTxTime for TotalBidsWithTimeOutCheck: %v\n", err)
+	}
 	result := &TotalBidsWithTimeOutCheckResult{0, false}
 	var activeTokenIDs []string
 	//first, check bidList: end timeout bids
@@ -356,8 +900,12 @@ func (s *SmartContract) TotalBidsWithTimeOutCheck(ctx contractapi.TransactionCon
 		if err != nil {
 			return nil, fmt.Errorf("failed to getBid for TotalBidsWithTimeOutCheck: %v\n", err)
 		}
-		if currentTime-bid.CreateTime > bid.LifeTime {
-			//timeout
+		format, err := getAuctionFormat(bid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to TotalBidsWithTimeOutCheck: %v\n", err)
+		}
+		if format.ended(bid, currentTime) {
+			//timeout or sold
 			result.HasTimeOutBid = true
 
 		} else {
@@ -381,158 +929,238 @@ func (s *SmartContract) TotalNFTs(ctx contractapi.TransactionContextInterface) (
 }
 
 func getBidsList(ctx contractapi.TransactionContextInterface) ([]string, error) {
-	key, err := ctx.GetStub().CreateCompositeKey(NFTBidListsPrefix, []string{""})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create composite key %v\n", err)
-	}
-	jvalue, err := ctx.GetStub().GetState(key)
+	tokenIDs, err := getIndexedTokenIDs(ctx, AuctionIndexPrefix, []string{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+		return nil, fmt.Errorf("failed to get auction index: %v\n", err)
 	}
-	value := string(jvalue)
-	strs := strings.Fields(value)
-	fmt.Printf("get all bids %v\n", strs)
-	return strs, nil
+	fmt.Printf("get all bids %v\n", tokenIDs)
+	return tokenIDs, nil
 }
 
 func removeBidFromList(ctx contractapi.TransactionContextInterface, tokenID string) error {
-	key, err := ctx.GetStub().CreateCompositeKey(NFTBidListsPrefix, []string{""})
+	key, err := ctx.GetStub().CreateCompositeKey(AuctionIndexPrefix, []string{tokenID})
 	if err != nil {
 		return fmt.Errorf("failed to create composite key %v\n", err)
 	}
-	jvalue, err := ctx.GetStub().GetState(key)
+	value, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return fmt.Errorf("failed to getstate for key: %s, %v", key, err)
 	}
-	value := string(jvalue)
-	strs := strings.Fields(value)
-
-	newstring := ""
-	skip := false
-	for i := 0; i < len(strs); i++ {
-		if strs[i] != tokenID {
-			newstring += strs[i] + " "
-		} else {
-			skip = true
-		}
-	}
-
-	if !skip {
+	if len(value) == 0 {
 		return fmt.Errorf("failed to removeBidFromList, tokenID not in BidList\n")
 	}
-	return ctx.GetStub().PutState(key, []byte(newstring))
+	return ctx.GetStub().DelState(key)
 }
 
 func removeNFTFromList(ctx contractapi.TransactionContextInterface, tokenID string, account string) error {
-	key, err := ctx.GetStub().CreateCompositeKey(NFTListsPrefix, []string{account})
+	key, err := ctx.GetStub().CreateCompositeKey(OwnerIndexPrefix, []string{account, tokenID})
 	if err != nil {
 		return fmt.Errorf("failed to create composite key %v\n", err)
 	}
-	jvalue, err := ctx.GetStub().GetState(key)
+	value, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return fmt.Errorf("failed to getstate for key: %s, %v", key, err)
 	}
-	value := string(jvalue)
-	strs := strings.Fields(value)
-	fmt.Printf("@@@@@@removeNFTFromList: %v from %v\n", tokenID, strs)
-	newstring := ""
-	skip := false
-	for i := 0; i < len(strs); i++ {
-		if strs[i] != tokenID {
-			newstring += strs[i] + " "
-		} else {
-			skip = true
-		}
-	}
-
-	if !skip {
+	if len(value) == 0 {
 		return fmt.Errorf("failed to removeNFTFromList, tokenID not in NFTList\n")
 	}
-	return ctx.GetStub().PutState(key, []byte(newstring))
+	return ctx.GetStub().DelState(key)
 }
 
 func getNFTList(ctx contractapi.TransactionContextInterface, account string) ([]string, error) {
-	key, _ := ctx.GetStub().CreateCompositeKey(NFTListsPrefix, []string{account})
-	jvalue, err := ctx.GetStub().GetState(key)
+	return getIndexedTokenIDs(ctx, OwnerIndexPrefix, []string{account})
+}
+
+func addBidsToList(ctx contractapi.TransactionContextInterface, newTokenID string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(AuctionIndexPrefix, []string{newTokenID})
 	if err != nil {
-		return nil, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+		return fmt.Errorf("failed to create composite key %v\n", err)
 	}
-	value := string(jvalue)
-	strs := strings.Fields(value)
-	return strs, nil
+	fmt.Printf("AddBidToList, %v\n", newTokenID)
+	return ctx.GetStub().PutState(key, []byte{0x00})
 }
-func addBidsToList(ctx contractapi.TransactionContextInterface, newTokenID string) error {
-	key, err := ctx.GetStub().CreateCompositeKey(NFTBidListsPrefix, []string{""})
+
+func addNFTToList(ctx contractapi.TransactionContextInterface, account string, tokenID string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(OwnerIndexPrefix, []string{account, tokenID})
 	if err != nil {
 		return fmt.Errorf("failed to create composite key %v\n", err)
 	}
-	jvalue, err := ctx.GetStub().GetState(key)
+	return ctx.GetStub().PutState(key, []byte{0x00})
+}
+
+// getIndexedTokenIDs returns the tokenIDs of every index entry under the partial key (prefix, attributes...).
+// The tokenID is always the last attribute of an index key.
+func getIndexedTokenIDs(ctx contractapi.TransactionContextInterface, prefix string, attributes []string) ([]string, error) {
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey(prefix, attributes)
 	if err != nil {
-		return fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+		return nil, fmt.Errorf("failed to GetStateByPartialCompositeKey for %s: %v\n", prefix, err)
 	}
-	value := string(jvalue)
-	//check existence
-	bids := strings.Fields(value)
-	for i := 0; i < len(bids); i++ {
-		if bids[i] == newTokenID {
-			return nil
+	defer iter.Close()
+
+	var tokenIDs []string
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate %s: %v\n", prefix, err)
+		}
+		_, parts, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key %v\n", err)
 		}
+		if len(parts) == 0 {
+			continue
+		}
+		tokenIDs = append(tokenIDs, parts[len(parts)-1])
 	}
-	value += " " + newTokenID
-	jvalue = []byte(value)
-	fmt.Printf("AddBidToList, %v\n", value)
-	return ctx.GetStub().PutState(key, jvalue)
+	return tokenIDs, nil
 }
 
-func addNFTToList(ctx contractapi.TransactionContextInterface, account string, tokenID string) error {
-	key, _ := ctx.GetStub().CreateCompositeKey(NFTListsPrefix, []string{account})
+// MigrateListIndexes rebuilds the per-item OwnerIndexPrefix/AuctionIndexPrefix keys from the NFT and
+// NFTBid records, and deletes the legacy whitespace-joined lists stored under NFTListsPrefix and
+// NFTBidListsPrefix. The legacy lists are not copied: TransferNFT never updated them, so they can
+// name a former holder of a token.
+// It returns the number of index entries written. Running it again is a no-op.
+func (s *SmartContract) MigrateListIndexes(ctx contractapi.TransactionContextInterface) (int, error) {
+	err := authorization(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to MigrateListIndexes, not authenticated: %v\n", err)
+	}
 
-	jvalue, err := ctx.GetStub().GetState(key)
+	migrated := 0
+	nftIter, err := ctx.GetStub().GetStateByPartialCompositeKey(NFTPrefix, []string{})
 	if err != nil {
-		return fmt.Errorf("failed to GetState for addNFTToList: %v\n", err)
+		return 0, fmt.Errorf("failed to GetStateByPartialCompositeKey for MigrateListIndexes: %v\n", err)
 	}
-	value := string(jvalue)
-	nfts := strings.Fields(value)
-	for i := 0; i < len(nfts); i++ {
-		if nfts[i] == tokenID {
-			return nil
+	defer nftIter.Close()
+	for nftIter.HasNext() {
+		kv, err := nftIter.Next()
+		if err != nil {
+			return 0, fmt.Errorf("failed to iterate nfts: %v\n", err)
+		}
+		nft := new(NFT)
+		err = json.Unmarshal(kv.Value, nft)
+		if err != nil {
+			return 0, fmt.Errorf("failed to unmarshal nft %s: %v\n", kv.Key, err)
+		}
+		if nft.Owner == "" {
+			continue
+		}
+		written, err := putIndexEntry(ctx, OwnerIndexPrefix, []string{nft.Owner, nft.ID})
+		if err != nil {
+			return 0, fmt.Errorf("failed to migrate nft %s of %s: %v\n", nft.ID, nft.Owner, err)
+		}
+		if written {
+			migrated++
+		}
+	}
+
+	bidIter, err := ctx.GetStub().GetStateByPartialCompositeKey(BidPrefix, []string{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to GetStateByPartialCompositeKey for MigrateListIndexes: %v\n", err)
+	}
+	defer bidIter.Close()
+	for bidIter.HasNext() {
+		kv, err := bidIter.Next()
+		if err != nil {
+			return 0, fmt.Errorf("failed to iterate bids: %v\n", err)
+		}
+		bid := new(NFTBid)
+		err = json.Unmarshal(kv.Value, bid)
+		if err != nil {
+			return 0, fmt.Errorf("failed to unmarshal bid %s: %v\n", kv.Key, err)
+		}
+		written, err := putIndexEntry(ctx, AuctionIndexPrefix, []string{bid.TokenID})
+		if err != nil {
+			return 0, fmt.Errorf("failed to migrate bid %s: %v\n", bid.TokenID, err)
+		}
+		if written {
+			migrated++
 		}
 	}
 
-	value += " " + tokenID
+	listIter, err := ctx.GetStub().GetStateByPartialCompositeKey(NFTListsPrefix, []string{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to GetStateByPartialCompositeKey for MigrateListIndexes: %v\n", err)
+	}
+	defer listIter.Close()
+	for listIter.HasNext() {
+		kv, err := listIter.Next()
+		if err != nil {
+			return 0, fmt.Errorf("failed to iterate nft lists: %v\n", err)
+		}
+		err = ctx.GetStub().DelState(kv.Key)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete nft list %s: %v\n", kv.Key, err)
+		}
+	}
 
-	jvalue = []byte(value)
-	return ctx.GetStub().PutState(key, jvalue)
+	key, err := ctx.GetStub().CreateCompositeKey(NFTBidListsPrefix, []string{""})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete bid list: %v\n", err)
+	}
+	return migrated, nil
+}
+
+// putIndexEntry writes the index key (prefix, attributes...) unless it already exists,
+// and reports whether it was written.
+func putIndexEntry(ctx contractapi.TransactionContextInterface, prefix string, attributes []string) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(prefix, attributes)
+	if err != nil {
+		return false, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	value, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+	}
+	if len(value) != 0 {
+		return false, nil
+	}
+	return true, ctx.GetStub().PutState(key, []byte{0x00})
 }
 
 func bidExists(ctx contractapi.TransactionContextInterface, tokenID string) (bool, error) {
-	lst, err := getBidsList(ctx)
+	key, err := ctx.GetStub().CreateCompositeKey(AuctionIndexPrefix, []string{tokenID})
 	if err != nil {
-		return false, fmt.Errorf("faled to getBidtList for IsNFTOnSale: %v\n", err)
+		return false, fmt.Errorf("failed to create composite key %v\n", err)
 	}
-	bidexist := false
-	for _, b := range lst {
-		if b == tokenID {
-			bidexist = true
-		}
+	value, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
 	}
-	return bidexist, nil
+	return len(value) != 0, nil
 }
 
 func nftExists(ctx contractapi.TransactionContextInterface, tokenID string) (bool, error) {
-	operator, _ := ctx.GetClientIdentity().GetID()
-	lst, err := getNFTList(ctx, operator)
+	key, err := ctx.GetStub().CreateCompositeKey(NFTPrefix, []string{tokenID})
 	if err != nil {
-		return false, fmt.Errorf("faled to getBidtList for IsNFTOnSale: %v\n", err)
+		return false, fmt.Errorf("failed to create composite key %v\n", err)
 	}
-	bidexist := false
-	for _, b := range lst {
-		if b == tokenID {
-			bidexist = true
-		}
+	value, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+	}
+	return len(value) != 0, nil
+}
+
+func accountExists(ctx contractapi.TransactionContextInterface, account string) (bool, error) {
+	if _, ok := cachedAccountBalance(ctx, account); ok {
+		return true, nil
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(BalancePrefix, []string{account})
+	if err != nil {
+		return false, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	value, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
 	}
-	return bidexist, nil
+	return len(value) != 0, nil
 }
+
 func getBid(ctx contractapi.TransactionContextInterface, tokenID string) (*NFTBid, error) {
 	nftkey, err := ctx.GetStub().CreateCompositeKey(BidPrefix, []string{tokenID})
 	if err != nil {
@@ -543,6 +1171,9 @@ func getBid(ctx contractapi.TransactionContextInterface, tokenID string) (*NFTBi
 	if err != nil {
 		return nil, fmt.Errorf("failed to getstate for key: %s, %v", nftkey, err)
 	}
+	if len(jvalue) == 0 {
+		return nil, fmt.Errorf("Bid not exist\n")
+	}
 	value := &NFTBid{}
 	err = json.Unmarshal(jvalue, value)
 	fmt.Printf("GetBid (%s: %v)\n", nftkey, jvalue)
@@ -552,19 +1183,38 @@ func getBid(ctx contractapi.TransactionContextInterface, tokenID string) (*NFTBi
 	return value, nil
 }
 
+func putBid(ctx contractapi.TransactionContextInterface, bid *NFTBid) error {
+	key, err := ctx.GetStub().CreateCompositeKey(BidPrefix, []string{bid.TokenID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := json.Marshal(bid)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data %v", err)
+	}
+	return ctx.GetStub().PutState(key, jvalue)
+}
+
+// InitAccountBalance opens account with balance on behalf of its owner, only the admin can open it.
+// It fails if the account already exists, use AdjustBalance to change an existing balance.
 func (s *SmartContract) InitAccountBalance(ctx contractapi.TransactionContextInterface, account string, balance uint64) error {
 	err := authorization(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to InitAccountBalance, not authenticated: %v\n", err)
 	}
 
-	ab := &AccountBalance{account, balance}
-	key, _ := ctx.GetStub().CreateCompositeKey(BalancePrefix, []string{account})
-	jvalue, err := json.Marshal(ab)
+	exists, err := accountExists(ctx, account)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("failed to InitAccountBalance, account already exists\n")
+	}
+	err = updateTotalSupply(ctx, balance, 0)
 	if err != nil {
-		return fmt.Errorf("failed to marshal data for newAccountBalance %v", err)
+		return fmt.Errorf("failed to updateTotalSupply for InitAccountBalance: %v\n", err)
 	}
-	err = ctx.GetStub().PutState(key, jvalue)
+	err = putAccountBalance(ctx, &AccountBalance{account, balance}, 0)
 	if err != nil {
 		return fmt.Errorf("failed to PutState for newAccountBalance: %v\n", err)
 	}
@@ -572,6 +1222,9 @@ func (s *SmartContract) InitAccountBalance(ctx contractapi.TransactionContextInt
 }
 
 func getAccountBalance(ctx contractapi.TransactionContextInterface, account string) (*AccountBalance, error) {
+	if ab, ok := cachedAccountBalance(ctx, account); ok {
+		return ab, nil
+	}
 	key, err := ctx.GetStub().CreateCompositeKey(BalancePrefix, []string{account})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create composite key %v\n", err)
@@ -592,65 +1245,47 @@ func getAccountBalance(ctx contractapi.TransactionContextInterface, account stri
 	return value, nil
 }
 
-func updateAccountBalance(ctx contractapi.TransactionContextInterface, account string, balance int) (*AccountBalance, error) {
-	/*
-		//only authored operator can update account balance
-		err := authorization(ctx)
-		if err != nil {
-			return nil,err
-		}*/
-
-	oldAccount, err := getAccountBalance(ctx, account)
-	if err != nil {
-		return nil, fmt.Errorf("failed to getAccountBalance: %v\n", err)
-	}
-	newbalance := int64(oldAccount.Balance) + int64(balance)
-	if newbalance < 0 {
-		newbalance = 0
-	}
-	value := &AccountBalance{
-		Account: account,
-		Balance: uint64(newbalance),
-	}
-	jvalue, err := json.Marshal(value)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal data %v", err)
-	}
-
-	key, err := ctx.GetStub().CreateCompositeKey(BalancePrefix, []string{account})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create composite key %v\n", err)
-	}
-	err = ctx.GetStub().PutState(key, jvalue)
-	fmt.Printf("Update Account (%v,%v)\n", key, value)
-	if err != nil {
-		return nil, fmt.Errorf("failed to PutState %v\n", err)
-	}
-	return value, nil
-}
-
 func (s *SmartContract) GetBid(ctx contractapi.TransactionContextInterface, tokenID string) (*NFTBid, error) {
 	return getBid(ctx, tokenID)
 }
 
-func (s *SmartContract) MintWithFile(ctx contractapi.TransactionContextInterface, tokenID string, ftype string, hash string) (*NFT, error) {
+// MintWithFile mints tokenID from the file uploaded to the web server, adding it to IPFS from the chaincode.
+// The result depends on each endorsing peer reaching IPFS, MintWithCID is the deterministic mint.
+func (s *SmartContract) MintWithFile(ctx contractapi.TransactionContextInterface, tokenID string, ftype string, hash string, royaltyBasisPoints uint64) (*NFT, error) {
 	//check operator balance
 	operator, err := ctx.GetClientIdentity().GetID()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get client id: %v", err)
 	}
-	balance, err := getAccountBalance(ctx, operator)
+	config, err := getConfig(ctx)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to getConfig for MintWithFile: %v\n", err)
 	}
-	if balance.Balance < MINT_FEE {
-		return nil, fmt.Errorf("failed to MintWithFile, no enough balance. has: %d, need at least: %d\n", balance, MINT_FEE)
+	err = checkMint(ctx, config, operator, royaltyBasisPoints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to MintWithFile, %v\n", err)
+	}
+	value := &NFT{
+		ID:                 tokenID,
+		FileType:           ftype,
+		RoyaltyBasisPoints: royaltyBasisPoints,
+	}
+	err = setCID(value, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to MintWithFile, %v\n", err)
 	}
 
-	sh := shell.NewShell("ipfs_host:5001")
-
-	cid, erripfs := sh.Add(strings.NewReader(ADDPREFIX + tokenID + "." + ftype))
-	fmt.Printf("ADD to IPFS: %s%s.%s", ADDPREFIX, tokenID, ftype)
+	//add through the first IPFS endpoint that answers
+	var sh *shell.Shell
+	var cid string
+	var erripfs error
+	for _, endpoint := range config.IPFSEndpoints {
+		sh = shell.NewShell(endpoint)
+		cid, erripfs = sh.Add(strings.NewReader(ADDPREFIX + tokenID + "." + ftype))
+		if erripfs == nil {
+			break
+		}
+	}
 	if erripfs != nil {
 		fmt.Println(erripfs.Error())
 		fmt.Println("trying to find file in IPFS network...")
@@ -673,82 +1308,46 @@ func (s *SmartContract) MintWithFile(ctx contractapi.TransactionContextInterface
 
 		providers := strings.Split(string(buf), " ")
 		if providers[0] != "find" {
-			return nil, fmt.Errorf("failed to find providers: %v\n", err.Error())
+			return nil, fmt.Errorf("failed to find providers of %s\n", hash)
 		}
 		//return nil, fmt.Errorf("failed to add file %v", erripfs)
-	}else{
+	} else {
 		//add successfully, means the local file exists in server
-		if cid!=hash{
+		added, err := parseCID(cid)
+		if err != nil || added.CID != value.CID {
 			fmt.Println("Mint Error, since file content has changed")
 			return nil, fmt.Errorf("Mint Error, since file content has changed")
 		}
 	}
 
-
 	// Mint tokens
-	value := &NFT{
-		ID:       tokenID,
-		CID:      cid,
-		Owner:    operator,
-		FileType: ftype,
-	}
-	jvalue, err := json.Marshal(value)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal data %v", err)
-	}
-
-	nftkey, err := ctx.GetStub().CreateCompositeKey(NFTPrefix, []string{tokenID})
+	err = storeMint(ctx, config, operator, value)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create composite key %v\n", err)
-	}
-	err = ctx.GetStub().PutState(nftkey, jvalue)
-	if err != nil {
-		return nil, fmt.Errorf("failed to PutState for MintWithFile %v\n", err)
-	}
-	_, err = updateAccountBalance(ctx, operator, -1*MINT_FEE)
-	if err != nil {
-		return nil, fmt.Errorf("failed to updateAccountBalance for MintWithFile: %v\n", err)
-	}
-	err = addNFTToList(ctx, operator, tokenID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to addNFTToList: %v", err)
+		return nil, fmt.Errorf("failed to MintWithFile: %v\n", err)
 	}
-	// Emit TransferSingle event
 	return value, nil
 }
 
+// TransferNFT sends tokenID from its current owner to recipientToken. It can be called by the owner,
+// or by an account approved for the token or for all of the owner's tokens, and fails while the token is on sale.
 func (s *SmartContract) TransferNFT(ctx contractapi.TransactionContextInterface, recipientToken string, tokenID string) error {
-	// only authorized account has right to transfer NFT (admin in org1)
-	err := authorization(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to TransfetNFT, not authenticated: %v\n", err)
-	}
-	nftkey, err := ctx.GetStub().CreateCompositeKey(NFTPrefix, []string{tokenID})
-	if err != nil {
-		return fmt.Errorf("failed to create composite key %v\n", err)
-	}
-	jv, err := ctx.GetStub().GetState(nftkey)
-	if err != nil {
-		return fmt.Errorf("failed to getstate for key: %s, %v", nftkey, err)
-	}
-	v := &NFT{}
-	err = json.Unmarshal(jv, v)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshal data %v", err)
-	}
-	v.Owner = recipientToken
-	jv, err = json.Marshal(v)
+	nft, err := getNFT(ctx, tokenID)
 	if err != nil {
-		return fmt.Errorf("failed to marshal data %v", err)
+		return fmt.Errorf("failed to getNFT for TransferNFT: %v\n", err)
 	}
-	err = ctx.GetStub().PutState(nftkey, jv)
+	err = transferFrom(ctx, nft.Owner, recipientToken, tokenID)
 	if err != nil {
-		return fmt.Errorf("failed to putstate for key %s , %v", nftkey, err)
+		return fmt.Errorf("failed to TransferNFT: %v\n", err)
 	}
 	fmt.Printf("===successfully transfer nft to %s==\n", recipientToken)
 	return nil
 }
 
+// Transfer is the name used by the web server for TransferNFT.
+func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, recipientToken string, tokenID string) error {
+	return s.TransferNFT(ctx, recipientToken, tokenID)
+}
+
 func (s *SmartContract) GetNFTByIndex(ctx contractapi.TransactionContextInterface, index uint64) (*NFT, error) {
 	operator, _ := ctx.GetClientIdentity().GetID()
 	nfts, err := getNFTList(ctx, operator)
@@ -778,6 +1377,9 @@ func getNFT(ctx contractapi.TransactionContextInterface, id string) (*NFT, error
 	if err != nil {
 		return nil, fmt.Errorf("failed to getstate for key: %s, %v", nftkey, err)
 	}
+	if len(jvalue) == 0 {
+		return nil, fmt.Errorf("NFT not exist\n")
+	}
 	value := &NFT{}
 	err = json.Unmarshal(jvalue, value)
 	if err != nil {
@@ -786,6 +1388,18 @@ func getNFT(ctx contractapi.TransactionContextInterface, id string) (*NFT, error
 	return value, nil
 }
 
+func putNFT(ctx contractapi.TransactionContextInterface, nft *NFT) error {
+	nftkey, err := ctx.GetStub().CreateCompositeKey(NFTPrefix, []string{nft.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := json.Marshal(nft)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data %v", err)
+	}
+	return ctx.GetStub().PutState(nftkey, jvalue)
+}
+
 func (s *SmartContract) GetNFTByID(ctx contractapi.TransactionContextInterface, tokenID string) (*NFT, error) {
 	value, err := getNFT(ctx, tokenID)
 	if err != nil {
@@ -796,33 +1410,40 @@ func (s *SmartContract) GetNFTByID(ctx contractapi.TransactionContextInterface,
 	return value, nil
 }
 
+// Request returns the content of tokenID, fetched from IPFS. The caller must own the token, hold an
+// edition of a token class, or hold a license given with GrantAccess.
 func (s *SmartContract) Request(ctx contractapi.TransactionContextInterface, tokenID string) (string, error) {
 	//get target nft
-	nftkey, err := ctx.GetStub().CreateCompositeKey(NFTPrefix, []string{tokenID})
+	value, err := getNFT(ctx, tokenID)
 	if err != nil {
-		return "", fmt.Errorf("failed to create composite key %v\n", err)
+		return "", fmt.Errorf("failed to getNFT for Request: %v\n", err)
 	}
-	jvalue, err := ctx.GetStub().GetState(nftkey)
+
+	// check if operator has the permission to request data
+	operator, err := ctx.GetClientIdentity().GetID()
 	if err != nil {
-		return "", fmt.Errorf("failed to getstate for key: %s, %v", nftkey, err)
+		return "", fmt.Errorf("failed to get client id: %v", err)
 	}
-	value := &NFT{}
-	err = json.Unmarshal(jvalue, value)
+	allowed, err := canAccess(ctx, value, operator)
 	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal data %v", err)
+		return "", fmt.Errorf("failed to check access for Request: %v\n", err)
+	}
+	if !allowed {
+		return "", fmt.Errorf("failed to request data, operator is not the owner nor a licensee\n")
 	}
-
-	/*
-		// check if operator has the permission to request data
-		operator,_:=ctx.GetClientIdentity().GetID()
-		if operator!=value.Owner{
-			return "",fmt.Errorf("failed to request data, operator is not the owner {"+operator+" , "+value.Owner+"}")
-		}
-	*/
 	//fetch data from ipfs
 	cid := value.CID
-	sh := shell.NewShell("ipfs_host:5001")
-	reader, err := sh.Cat(cid)
+	config, err := getConfig(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to getConfig for Request: %v\n", err)
+	}
+	var reader io.ReadCloser
+	for _, endpoint := range config.IPFSEndpoints {
+		reader, err = shell.NewShell(endpoint).Cat(cid)
+		if err == nil {
+			break
+		}
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to get data with cid %s from ipfs %v", cid, err)
 	}
@@ -834,16 +1455,182 @@ func (s *SmartContract) Request(ctx contractapi.TransactionContextInterface, tok
 	return buf.String(), nil
 }
 
-// authorizationHelper checks minter authorization - this sample assumes Org1 is the central banker with privilege to mint new tokens
+// authorizationHelper checks admin authorization - clients of the Config.AdminMSPIDs, Org1 by default, are the central banker with privilege to mint new tokens
 func authorization(ctx contractapi.TransactionContextInterface) error {
 
 	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
 	if err != nil {
 		return fmt.Errorf("failed to get MSPID: %v", err)
 	}
-	if clientMSPID != AdmintMSPID {
-		return fmt.Errorf("client is not authorized")
+	config, err := getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	for _, mspID := range config.AdminMSPIDs {
+		if clientMSPID == mspID {
+			return nil
+		}
+	}
+	return fmt.Errorf("client is not authorized")
+}
+
+type NFTPage struct {
+	Records             []*NFT
+	FetchedRecordsCount int32
+	Bookmark            string
+}
+
+type BidPage struct {
+	Records             []*NFTBid
+	FetchedRecordsCount int32
+	Bookmark            string
+}
+
+type AccountPage struct {
+	Records             []*AccountBalance
+	FetchedRecordsCount int32
+	Bookmark            string
+}
+
+// ListNFTsByOwner returns one page of the NFTs owned by owner. Pass the returned Bookmark to fetch the next page,
+// an empty Bookmark means the last page has been reached.
+func (s *SmartContract) ListNFTsByOwner(ctx contractapi.TransactionContextInterface, owner string, pageSize int32, bookmark string) (*NFTPage, error) {
+	keys, meta, err := getIndexPage(ctx, OwnerIndexPrefix, []string{owner}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getIndexPage for ListNFTsByOwner: %v\n", err)
+	}
+	page := &NFTPage{Records: []*NFT{}, FetchedRecordsCount: meta.FetchedRecordsCount, Bookmark: meta.Bookmark}
+	for _, tokenID := range keys {
+		nft, err := getNFT(ctx, tokenID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to getNFT %s for ListNFTsByOwner: %v\n", tokenID, err)
+		}
+		page.Records = append(page.Records, nft)
+	}
+	return page, nil
+}
+
+// ListActiveAuctions returns one page of the auctions that have not ended yet.
+// A page can hold fewer than pageSize records when ended auctions are skipped.
+func (s *SmartContract) ListActiveAuctions(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*BidPage, error) {
+	keys, meta, err := getIndexPage(ctx, AuctionIndexPrefix, []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getIndexPage for ListActiveAuctions: %v\n", err)
+	}
+	currentTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getTxTime for ListActiveAuctions: %v\n", err)
+	}
+	page := &BidPage{Records: []*NFTBid{}, FetchedRecordsCount: meta.FetchedRecordsCount, Bookmark: meta.Bookmark}
+	for _, tokenID := range keys {
+		bid, err := getBid(ctx, tokenID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to getBid %s for ListActiveAuctions: %v\n", tokenID, err)
+		}
+		format, err := getAuctionFormat(bid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to ListActiveAuctions: %v\n", err)
+		}
+		if format.ended(bid, currentTime) {
+			continue
+		}
+		page.Records = append(page.Records, bid)
+	}
+	return page, nil
+}
+
+func (s *SmartContract) ListAllNFTs(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*NFTPage, error) {
+	err := checkPageSize(pageSize)
+	if err != nil {
+		return nil, err
+	}
+	iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(NFTPrefix, []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetStateByPartialCompositeKeyWithPagination for ListAllNFTs: %v\n", err)
+	}
+	defer iter.Close()
+
+	page := &NFTPage{Records: []*NFT{}, FetchedRecordsCount: meta.FetchedRecordsCount, Bookmark: meta.Bookmark}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate nfts: %v\n", err)
+		}
+		nft := &NFT{}
+		err = json.Unmarshal(kv.Value, nft)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data %v", err)
+		}
+		page.Records = append(page.Records, nft)
+	}
+	return page, nil
+}
+
+// ListAccounts returns one page of account balances, only the admin can list other accounts.
+func (s *SmartContract) ListAccounts(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*AccountPage, error) {
+	err := authorization(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ListAccounts, not authenticated: %v\n", err)
+	}
+	err = checkPageSize(pageSize)
+	if err != nil {
+		return nil, err
+	}
+	iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(BalancePrefix, []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetStateByPartialCompositeKeyWithPagination for ListAccounts: %v\n", err)
+	}
+	defer iter.Close()
+
+	page := &AccountPage{Records: []*AccountBalance{}, FetchedRecordsCount: meta.FetchedRecordsCount, Bookmark: meta.Bookmark}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate accounts: %v\n", err)
+		}
+		ab := &AccountBalance{}
+		err = json.Unmarshal(kv.Value, ab)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data %v", err)
+		}
+		page.Records = append(page.Records, ab)
+	}
+	return page, nil
+}
+
+// getIndexPage returns the tokenIDs of one page of index entries under the partial key (prefix, attributes...).
+func getIndexPage(ctx contractapi.TransactionContextInterface, prefix string, attributes []string, pageSize int32, bookmark string) ([]string, *peer.QueryResponseMetadata, error) {
+	err := checkPageSize(pageSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(prefix, attributes, pageSize, bookmark)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to GetStateByPartialCompositeKeyWithPagination for %s: %v\n", prefix, err)
+	}
+	defer iter.Close()
+
+	var tokenIDs []string
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to iterate %s: %v\n", prefix, err)
+		}
+		_, parts, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to split composite key %v\n", err)
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		tokenIDs = append(tokenIDs, parts[len(parts)-1])
 	}
+	return tokenIDs, meta, nil
+}
 
+func checkPageSize(pageSize int32) error {
+	if pageSize <= 0 || pageSize > MAX_PAGE_SIZE {
+		return fmt.Errorf("page size must be in [1,%d], got %d\n", MAX_PAGE_SIZE, pageSize)
+	}
 	return nil
 }
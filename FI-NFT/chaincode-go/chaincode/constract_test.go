@@ -0,0 +1,782 @@
+package chaincode
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// testIdentity is a client identity with a fixed ID, the tests use plain account names as IDs.
+type testIdentity struct {
+	id    string
+	mspID string
+}
+
+func (i *testIdentity) GetID() (string, error)                         { return i.id, nil }
+func (i *testIdentity) GetMSPID() (string, error)                      { return i.mspID, nil }
+func (i *testIdentity) GetAttributeValue(string) (string, bool, error) { return "", false, nil }
+func (i *testIdentity) AssertAttributeValue(string, string) error      { return nil }
+func (i *testIdentity) GetX509Certificate() (*x509.Certificate, error) { return nil, nil }
+
+// testStub adds the transient map and paginated queries that shimtest.MockStub leaves out.
+type testStub struct {
+	*shimtest.MockStub
+	transient map[string][]byte
+}
+
+func (s *testStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+// GetStateByPartialCompositeKeyWithPagination pages over the sorted keys, the bookmark is the first key of the next page.
+func (s *testStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	iter, err := s.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iter.Close()
+	page := &testIterator{}
+	next := ""
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if kv.Key < bookmark {
+			continue
+		}
+		if int32(len(page.kvs)) == pageSize {
+			next = kv.Key
+			break
+		}
+		page.kvs = append(page.kvs, kv)
+	}
+	return page, &peer.QueryResponseMetadata{FetchedRecordsCount: int32(len(page.kvs)), Bookmark: next}, nil
+}
+
+type testIterator struct {
+	kvs []*queryresult.KV
+}
+
+func (i *testIterator) HasNext() bool { return len(i.kvs) > 0 }
+func (i *testIterator) Close() error  { return nil }
+func (i *testIterator) Next() (*queryresult.KV, error) {
+	kv := i.kvs[0]
+	i.kvs = i.kvs[1:]
+	return kv, nil
+}
+
+// testLedger runs the transactions of a test against one MockStub. Every call to ctx starts a new
+// transaction at now, with a new TxID and the current transient map.
+type testLedger struct {
+	t         *testing.T
+	stub      *shimtest.MockStub
+	now       time.Time
+	transient map[string][]byte
+	txs       int
+}
+
+func newTestLedger(t *testing.T) *testLedger {
+	return &testLedger{
+		t:    t,
+		stub: shimtest.NewMockStub("fi-nft", nil),
+		now:  time.Unix(1700000000, 0),
+	}
+}
+
+// ctx starts a transaction submitted by account, a client of Org2MSP.
+func (l *testLedger) ctx(account string) *TransactionContext {
+	return l.ctxOf(account, "Org2MSP")
+}
+
+// adminCtx starts a transaction submitted by an admin, a client of AdmintMSPID.
+func (l *testLedger) adminCtx() *TransactionContext {
+	return l.ctxOf("admin", AdmintMSPID)
+}
+
+func (l *testLedger) ctxOf(account string, mspID string) *TransactionContext {
+	l.txs++
+	l.stub.TxID = fmt.Sprintf("tx%d", l.txs)
+	l.stub.TxTimestamp = &timestamp.Timestamp{Seconds: l.now.Unix(), Nanos: int32(l.now.Nanosecond())}
+	ctx := &TransactionContext{}
+	ctx.SetStub(&testStub{MockStub: l.stub, transient: l.transient})
+	ctx.SetClientIdentity(&testIdentity{id: account, mspID: mspID})
+	return ctx
+}
+
+// nowMillis is the transaction time of the next transaction, as returned by getTxTime.
+func (l *testLedger) nowMillis() uint64 {
+	return uint64(l.now.UnixNano() / int64(time.Millisecond))
+}
+
+func (l *testLedger) advance(minutes int) {
+	l.now = l.now.Add(time.Duration(minutes) * time.Minute)
+}
+
+// openAccounts opens every account with balance.
+func (l *testLedger) openAccounts(balance uint64, accounts ...string) {
+	l.t.Helper()
+	for _, account := range accounts {
+		mustSucceed(l.t, new(SmartContract).InitAccountBalance(l.adminCtx(), account, balance))
+	}
+}
+
+func (l *testLedger) balance(account string) uint64 {
+	l.t.Helper()
+	ab, err := getAccountBalance(l.ctx(account), account)
+	mustSucceed(l.t, err)
+	return ab.Balance
+}
+
+func (l *testLedger) expectBalance(account string, want uint64) {
+	l.t.Helper()
+	if got := l.balance(account); got != want {
+		l.t.Fatalf("balance of %s is %d, want %d", account, got, want)
+	}
+}
+
+// seedNFT stores a unique token owned by owner, as if it had been minted before royalties and fees.
+func (l *testLedger) seedNFT(tokenID string, owner string) {
+	l.t.Helper()
+	ctx := l.adminCtx()
+	mustSucceed(l.t, putNFT(ctx, &NFT{ID: tokenID, CID: "Qm" + tokenID, Owner: owner}))
+	mustSucceed(l.t, addNFTToList(ctx, owner, tokenID))
+}
+
+func (l *testLedger) owner(tokenID string) string {
+	l.t.Helper()
+	nft, err := getNFT(l.ctx("reader"), tokenID)
+	mustSucceed(l.t, err)
+	return nft.Owner
+}
+
+// eventNames lists the events queued on ctx, in order.
+func eventNames(ctx *TransactionContext) []string {
+	var names []string
+	for _, event := range ctx.events {
+		names = append(names, event.Name)
+	}
+	return names
+}
+
+func mustSucceed(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustFail(t *testing.T, err error, want string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected an error containing %q", want)
+	}
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("error %q does not contain %q", err, want)
+	}
+}
+
+func TestOfferHoldsFundsInEscrow(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice", "bob")
+	l.seedNFT("1", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", AuctionEnglish, 100, 1000, 10)
+	mustSucceed(t, err)
+
+	mustSucceed(t, s.Offer(l.ctx("alice"), 200, "1"))
+	l.expectBalance("alice", 800)
+	escrow, err := s.GetEscrow(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if escrow.Bidder != "alice" || escrow.Amount != 200 {
+		t.Fatalf("escrow is %+v", escrow)
+	}
+
+	// raising its own bid only holds the difference
+	_, err = s.UpdateBid(l.ctx("alice"), "1", 250)
+	mustSucceed(t, err)
+	l.expectBalance("alice", 750)
+
+	// outbidding refunds the previous top bidder in full
+	mustSucceed(t, s.Offer(l.ctx("bob"), 300, "1"))
+	l.expectBalance("alice", 1000)
+	l.expectBalance("bob", 700)
+	escrow, err = s.GetEscrow(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if escrow.Bidder != "bob" || escrow.Amount != 300 {
+		t.Fatalf("escrow is %+v", escrow)
+	}
+}
+
+func TestOfferRejectsBidAboveAvailableBalance(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	l.seedNFT("2", "seller")
+	for _, tokenID := range []string{"1", "2"} {
+		_, err := s.AddAuction(l.ctx("seller"), tokenID, AuctionEnglish, 100, 1000, 10)
+		mustSucceed(t, err)
+	}
+
+	mustSucceed(t, s.Offer(l.ctx("alice"), 700, "1"))
+	// the 700 held on token 1 cannot back a second bid
+	mustFail(t, s.Offer(l.ctx("alice"), 400, "2"), "insufficient funds")
+	l.expectBalance("alice", 300)
+	_, err := s.GetEscrow(l.ctx("reader"), "2")
+	mustFail(t, err, "Escrow not exist")
+}
+
+func TestEndBidPaysSellerFromEscrow(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice", "bob")
+	l.seedNFT("1", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", AuctionEnglish, 100, 1000, 10)
+	mustSucceed(t, err)
+	mustSucceed(t, s.Offer(l.ctx("alice"), 200, "1"))
+	mustSucceed(t, s.Offer(l.ctx("bob"), 300, "1"))
+
+	l.advance(11)
+	mustSucceed(t, s.TryEndBid(l.ctx("reader"), "1"))
+	l.expectBalance("seller", 1300)
+	l.expectBalance("alice", 1000)
+	l.expectBalance("bob", 700)
+	if owner := l.owner("1"); owner != "bob" {
+		t.Fatalf("owner is %s", owner)
+	}
+	_, err = s.GetEscrow(l.ctx("reader"), "1")
+	mustFail(t, err, "Escrow not exist")
+}
+
+func TestAuctionTimingFollowsTxTimestamp(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	createTime := l.nowMillis()
+	bid, err := s.AddAuction(l.ctx("seller"), "1", AuctionEnglish, 100, 1000, 10)
+	mustSucceed(t, err)
+	if bid.CreateTime != createTime || bid.LifeTime != 10*60*1000 {
+		t.Fatalf("auction created at %d for %d", bid.CreateTime, bid.LifeTime)
+	}
+	mustSucceed(t, s.Offer(l.ctx("alice"), 200, "1"))
+
+	l.advance(10)
+	result, err := s.CanBidEnd(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if result.CanEnd || result.EndTime != createTime+10*60*1000 {
+		t.Fatalf("CanBidEnd is %+v at the end time", result)
+	}
+	mustSucceed(t, s.TryEndBid(l.ctx("reader"), "1"))
+	if owner := l.owner("1"); owner != "seller" {
+		t.Fatalf("auction settled early, owner is %s", owner)
+	}
+
+	l.now = l.now.Add(time.Millisecond)
+	mustFail(t, s.Offer(l.ctx("alice"), 300, "1"), "auction has ended")
+	mustSucceed(t, s.TryEndBid(l.ctx("reader"), "1"))
+	if owner := l.owner("1"); owner != "alice" {
+		t.Fatalf("owner is %s", owner)
+	}
+}
+
+func TestMigrateListIndexes(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	ctx := l.adminCtx()
+	for _, tokenID := range []string{"1", "2"} {
+		mustSucceed(t, putNFT(ctx, &NFT{ID: tokenID, CID: "Qm" + tokenID, Owner: "alice"}))
+	}
+	//token 3 was transferred to bob by the legacy TransferNFT, which left it in alice's list
+	mustSucceed(t, putNFT(ctx, &NFT{ID: "3", CID: "Qm3", Owner: "bob"}))
+	mustSucceed(t, putBid(ctx, &NFTBid{TokenID: "2", Seller: "alice"}))
+	key, err := ctx.GetStub().CreateCompositeKey(NFTListsPrefix, []string{"alice"})
+	mustSucceed(t, err)
+	mustSucceed(t, ctx.GetStub().PutState(key, []byte("1 2  3")))
+	bidsKey, err := ctx.GetStub().CreateCompositeKey(NFTBidListsPrefix, []string{""})
+	mustSucceed(t, err)
+	mustSucceed(t, ctx.GetStub().PutState(bidsKey, []byte("2 ")))
+
+	_, err = s.MigrateListIndexes(l.ctx("alice"))
+	mustFail(t, err, "not authenticated")
+	migrated, err := s.MigrateListIndexes(l.adminCtx())
+	mustSucceed(t, err)
+	if migrated != 4 {
+		t.Fatalf("migrated %d entries", migrated)
+	}
+	total, err := s.TotalNFTs(l.ctx("alice"))
+	mustSucceed(t, err)
+	if total != 2 {
+		t.Fatalf("alice has %d tokens", total)
+	}
+	mustSucceed(t, s.TransferNFT(l.ctx("bob"), "carol", "3"))
+	for account, want := range map[string]int{"alice": 2, "bob": 0, "carol": 1} {
+		balance, err := s.BalanceOf(l.ctx("reader"), account)
+		mustSucceed(t, err)
+		if balance != want {
+			t.Fatalf("%s has %d tokens, want %d", account, balance, want)
+		}
+	}
+	onSale, err := s.IsNFTOnSale(l.ctx("reader"), "2")
+	mustSucceed(t, err)
+	if !onSale {
+		t.Fatal("token 2 is not on sale")
+	}
+	for _, key := range []string{key, bidsKey} {
+		value, err := l.ctx("reader").GetStub().GetState(key)
+		mustSucceed(t, err)
+		if len(value) != 0 {
+			t.Fatalf("legacy list %s not deleted", key)
+		}
+	}
+
+	migrated, err = s.MigrateListIndexes(l.adminCtx())
+	mustSucceed(t, err)
+	if migrated != 0 {
+		t.Fatalf("second run migrated %d entries", migrated)
+	}
+}
+
+func TestOwnerIndexFollowsAuction(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	l.seedNFT("2", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", AuctionFixedPrice, 0, 100, 10)
+	mustSucceed(t, err)
+	mustSucceed(t, s.Offer(l.ctx("alice"), 100, "1"))
+	mustSucceed(t, s.FindBidToEnd(l.ctx("reader")))
+
+	for account, want := range map[string]int{"seller": 1, "alice": 1} {
+		total, err := s.TotalNFTs(l.ctx(account))
+		mustSucceed(t, err)
+		if total != want {
+			t.Fatalf("%s has %d tokens, want %d", account, total, want)
+		}
+	}
+	nft, err := s.GetNFTByIndex(l.ctx("alice"), 0)
+	mustSucceed(t, err)
+	if nft.ID != "1" {
+		t.Fatalf("alice's first token is %s", nft.ID)
+	}
+	onSale, err := s.IsNFTOnSale(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if onSale {
+		t.Fatal("settled auction still indexed")
+	}
+}
+
+func TestListNFTsByOwnerPages(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	for _, tokenID := range []string{"1", "2", "3", "4", "5"} {
+		l.seedNFT(tokenID, "alice")
+	}
+	l.seedNFT("6", "bob")
+
+	var got []string
+	bookmark := ""
+	for pages := 0; ; pages++ {
+		if pages == 3 {
+			t.Fatal("more than 3 pages of 2")
+		}
+		page, err := s.ListNFTsByOwner(l.ctx("reader"), "alice", 2, bookmark)
+		mustSucceed(t, err)
+		if page.FetchedRecordsCount != int32(len(page.Records)) {
+			t.Fatalf("page counts %d of %d records", page.FetchedRecordsCount, len(page.Records))
+		}
+		for _, nft := range page.Records {
+			got = append(got, nft.ID)
+		}
+		if page.Bookmark == "" {
+			break
+		}
+		bookmark = page.Bookmark
+	}
+	if strings.Join(got, ",") != "1,2,3,4,5" {
+		t.Fatalf("listed %v", got)
+	}
+
+	all, err := s.ListAllNFTs(l.ctx("reader"), MAX_PAGE_SIZE, "")
+	mustSucceed(t, err)
+	if len(all.Records) != 6 || all.Bookmark != "" {
+		t.Fatalf("listed %d tokens, bookmark %q", len(all.Records), all.Bookmark)
+	}
+	_, err = s.ListAllNFTs(l.ctx("reader"), 0, "")
+	mustFail(t, err, "page size")
+	_, err = s.ListNFTsByOwner(l.ctx("reader"), "alice", MAX_PAGE_SIZE+1, "")
+	mustFail(t, err, "page size")
+}
+
+func TestListActiveAuctionsSkipsEnded(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller")
+	l.seedNFT("1", "seller")
+	l.seedNFT("2", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", AuctionEnglish, 100, 1000, 5)
+	mustSucceed(t, err)
+	_, err = s.AddAuction(l.ctx("seller"), "2", AuctionEnglish, 100, 1000, 20)
+	mustSucceed(t, err)
+
+	l.advance(10)
+	page, err := s.ListActiveAuctions(l.ctx("reader"), 10, "")
+	mustSucceed(t, err)
+	if len(page.Records) != 1 || page.Records[0].TokenID != "2" || page.FetchedRecordsCount != 2 {
+		t.Fatalf("listed %d of %d auctions", len(page.Records), page.FetchedRecordsCount)
+	}
+}
+
+func TestListAccountsNeedsAdmin(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(10, "alice", "bob", "carol")
+	_, err := s.ListAccounts(l.ctx("alice"), 10, "")
+	mustFail(t, err, "not authenticated")
+	page, err := s.ListAccounts(l.adminCtx(), 2, "")
+	mustSucceed(t, err)
+	if len(page.Records) != 2 || page.Records[0].Account != "alice" || page.Bookmark == "" {
+		t.Fatalf("first page is %+v", page)
+	}
+	page, err = s.ListAccounts(l.adminCtx(), 2, page.Bookmark)
+	mustSucceed(t, err)
+	if len(page.Records) != 1 || page.Records[0].Account != "carol" || page.Bookmark != "" {
+		t.Fatalf("last page is %+v", page)
+	}
+}
+
+func TestTransferNFTMovesOwnerIndex(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.seedNFT("1", "alice")
+	l.seedNFT("2", "alice")
+
+	mustFail(t, s.TransferNFT(l.ctx("bob"), "bob", "1"), "not owner nor approved")
+	mustFail(t, s.TransferNFT(l.ctx("alice"), "bob", "3"), "NFT not exist")
+	mustSucceed(t, s.TransferNFT(l.ctx("alice"), "bob", "1"))
+	mustSucceed(t, s.Transfer(l.ctx("bob"), "carol", "1"))
+
+	for account, want := range map[string]string{"alice": "2", "bob": "", "carol": "1"} {
+		page, err := s.ListNFTsByOwner(l.ctx("reader"), account, 10, "")
+		mustSucceed(t, err)
+		var got []string
+		for _, nft := range page.Records {
+			got = append(got, nft.ID)
+		}
+		if strings.Join(got, ",") != want {
+			t.Fatalf("%s owns %v, want %s", account, got, want)
+		}
+	}
+}
+
+func TestCancelAuctionRefundsBidderWithPenalty(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openTreasury()
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	l.seedNFT("2", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", AuctionEnglish, 100, 1000, 10)
+	mustSucceed(t, err)
+	_, err = s.AddAuction(l.ctx("seller"), "2", AuctionEnglish, 100, 1000, 10)
+	mustSucceed(t, err)
+
+	// without a bid the auction is simply removed
+	mustSucceed(t, s.CancelAuction(l.ctx("seller"), "2"))
+
+	mustSucceed(t, s.Offer(l.ctx("alice"), 400, "1"))
+	mustFail(t, s.CancelAuction(l.ctx("alice"), "1"), "not Owner")
+	ctx := l.ctx("seller")
+	mustSucceed(t, s.CancelAuction(ctx, "1"))
+	// the default penalty is CANCEL_PENALTY_BASIS_POINTS of the bid
+	l.expectBalance("alice", 1020)
+	l.expectBalance("seller", 980)
+	cancelled := &AuctionCancelled{}
+	mustSucceed(t, json.Unmarshal(ctx.events[len(ctx.events)-1].Payload, cancelled))
+	if cancelled.Reason != "cancelled by seller, penalty 20 paid to bidder" {
+		t.Fatalf("cancel reason is %q", cancelled.Reason)
+	}
+	for _, tokenID := range []string{"1", "2"} {
+		onSale, err := s.IsNFTOnSale(l.ctx("reader"), tokenID)
+		mustSucceed(t, err)
+		if onSale {
+			t.Fatalf("token %s still on sale", tokenID)
+		}
+	}
+
+	config, err := s.GetConfig(l.ctx("reader"))
+	mustSucceed(t, err)
+	config.CancelPenaltyBasisPoints = 1000
+	_, err = s.SetConfig(l.adminCtx(), config)
+	mustSucceed(t, err)
+	_, err = s.AddAuction(l.ctx("seller"), "1", AuctionEnglish, 100, 1000, 10)
+	mustSucceed(t, err)
+	mustSucceed(t, s.Offer(l.ctx("alice"), 400, "1"))
+	mustSucceed(t, s.CancelAuction(l.ctx("seller"), "1"))
+	l.expectBalance("alice", 1060)
+	l.expectBalance("seller", 940)
+}
+
+func TestCancelAuctionRejectsEndedAuctionWithBid(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", AuctionEnglish, 100, 1000, 10)
+	mustSucceed(t, err)
+	mustSucceed(t, s.Offer(l.ctx("alice"), 200, "1"))
+	l.advance(11)
+	mustFail(t, s.CancelAuction(l.ctx("seller"), "1"), "must be settled")
+}
+
+func TestSellerCannotBidOnOwnAuction(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", AuctionEnglish, 100, 1000, 10)
+	mustSucceed(t, err)
+	mustFail(t, s.Offer(l.ctx("seller"), 200, "1"), "owner cannot bid")
+	mustSucceed(t, s.Offer(l.ctx("alice"), 200, "1"))
+	_, err = s.UpdateBid(l.ctx("seller"), "1", 300)
+	mustFail(t, err, "owner cannot bid")
+	l.expectBalance("seller", 1000)
+	l.expectBalance("alice", 800)
+}
+
+func TestRelistAuctionWithoutBids(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", AuctionEnglish, 100, 1000, 10)
+	mustSucceed(t, err)
+	_, err = s.RelistAuction(l.ctx("seller"), "1", 50, 500, 20)
+	mustFail(t, err, "still running")
+
+	l.advance(11)
+	_, err = s.RelistAuction(l.ctx("alice"), "1", 50, 500, 20)
+	mustFail(t, err, "not Owner")
+	_, err = s.RelistAuction(l.ctx("seller"), "1", 50, 500, MAX_LIFETIME+1)
+	mustFail(t, err, "exceed max time")
+	relistTime := l.nowMillis()
+	bid, err := s.RelistAuction(l.ctx("seller"), "1", 50, 500, 20)
+	mustSucceed(t, err)
+	if bid.CreateTime != relistTime || bid.LifeTime != 20*60*1000 || bid.LowerPrice != 50 || bid.KillPrice != 500 {
+		t.Fatalf("relisted auction is %+v", bid)
+	}
+
+	mustSucceed(t, s.Offer(l.ctx("alice"), 60, "1"))
+	l.advance(21)
+	_, err = s.RelistAuction(l.ctx("seller"), "1", 50, 500, 20)
+	mustFail(t, err, "has bids")
+}
+
+// setReservePrice passes a hidden reserve price and its salt in the transient map of the next transactions.
+func (l *testLedger) setReservePrice(price uint64, salt string) {
+	l.transient = map[string][]byte{
+		ReservePriceTransientKey: []byte(fmt.Sprint(price)),
+		ReserveSaltTransientKey:  []byte(salt),
+	}
+}
+
+func TestAddBidMinimumIncrement(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice", "bob")
+	l.seedNFT("1", "seller")
+	_, err := s.AddBid(l.ctx("seller"), "1", 500, 100, 10, 0, 0)
+	mustFail(t, err, "start price 500 is above kill price 100")
+	_, err = s.AddBid(l.ctx("seller"), "1", 100, 500, 10, 0, 10001)
+	mustFail(t, err, "exceeds 10000")
+	bid, err := s.AddBid(l.ctx("seller"), "1", 100, 500, 10, 5, 1000)
+	mustSucceed(t, err)
+	if bid.MinIncrement != 5 || bid.MinIncrementBasisPoints != 1000 {
+		t.Fatalf("increments are %d and %d", bid.MinIncrement, bid.MinIncrementBasisPoints)
+	}
+
+	mustFail(t, s.Offer(l.ctx("alice"), 99, "1"), "lower than start price")
+	mustSucceed(t, s.Offer(l.ctx("alice"), 100, "1"))
+	// 10% of 100 is larger than 5
+	mustFail(t, s.Offer(l.ctx("bob"), 109, "1"), "minimum next bid 110")
+	mustSucceed(t, s.Offer(l.ctx("bob"), 110, "1"))
+	mustFail(t, s.Offer(l.ctx("alice"), 120, "1"), "minimum next bid 121")
+	mustSucceed(t, s.Offer(l.ctx("alice"), 121, "1"))
+	// the increment never asks for more than the kill price
+	mustSucceed(t, s.Offer(l.ctx("bob"), 480, "1"))
+	mustSucceed(t, s.Offer(l.ctx("alice"), 500, "1"))
+}
+
+func TestStartPriceAboveKillPriceRejected(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller")
+	l.seedNFT("1", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", AuctionEnglish, 500, 100, 10)
+	mustFail(t, err, "start price 500 is above kill price 100")
+	_, err = s.AddAuction(l.ctx("seller"), "1", AuctionEnglish, 100, 500, 10)
+	mustSucceed(t, err)
+	l.advance(11)
+	_, err = s.RelistAuction(l.ctx("seller"), "1", 500, 50, 20)
+	mustFail(t, err, "start price 500 is above kill price 50")
+}
+
+func TestReservePriceStaysHiddenUntilRevealed(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	l.setReservePrice(600, "pepper")
+	_, err := s.AddBid(l.ctx("seller"), "1", 100, 500, 10, 0, 0)
+	mustFail(t, err, "reserve price must be between 100 and 500")
+	l.transient = map[string][]byte{ReservePriceTransientKey: []byte("300")}
+	_, err = s.AddBid(l.ctx("seller"), "1", 100, 500, 10, 0, 0)
+	mustFail(t, err, "salt not in transient map")
+	l.setReservePrice(300, "pepper")
+	_, err = s.AddBid(l.ctx("seller"), "1", 100, 500, 10, 0, 0)
+	mustSucceed(t, err)
+	l.transient = nil
+
+	reserve, err := getReservePrice(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if reserve.Hash != ReservePriceHash("1", 300, "pepper") || reserve.Price != 0 || reserve.Revealed {
+		t.Fatalf("stored reserve is %+v", reserve)
+	}
+	_, err = s.RevealReserve(l.ctx("seller"), "1")
+	mustFail(t, err, "still running")
+
+	mustSucceed(t, s.Offer(l.ctx("alice"), 200, "1"))
+	l.advance(11)
+	// the auction waits for the seller to reveal the reserve price
+	mustSucceed(t, s.FindBidToEnd(l.ctx("reader")))
+	result, err := s.CanBidEnd(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if result.CanEnd {
+		t.Fatal("auction can end before the reserve price is revealed")
+	}
+	l.setReservePrice(300, "pepper")
+	_, err = s.RevealReserve(l.ctx("alice"), "1")
+	mustFail(t, err, "not Owner")
+	l.setReservePrice(200, "pepper")
+	_, err = s.RevealReserve(l.ctx("seller"), "1")
+	mustFail(t, err, "do not match the hash")
+	l.setReservePrice(300, "pepper")
+	reserve, err = s.RevealReserve(l.ctx("seller"), "1")
+	mustSucceed(t, err)
+	if !reserve.Revealed || reserve.Price != 300 {
+		t.Fatalf("revealed reserve is %+v", reserve)
+	}
+
+	ctx := l.ctx("reader")
+	mustSucceed(t, s.TryEndBid(ctx, "1"))
+	cancelled := &AuctionCancelled{}
+	mustSucceed(t, json.Unmarshal(ctx.events[len(ctx.events)-1].Payload, cancelled))
+	if cancelled.Reason != "reserve price not met" {
+		t.Fatalf("cancel reason is %q", cancelled.Reason)
+	}
+	l.expectBalance("alice", 1000)
+	l.expectBalance("seller", 1000)
+	if owner := l.owner("1"); owner != "seller" {
+		t.Fatalf("owner is %s", owner)
+	}
+	reserve, err = getReservePrice(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if reserve != nil {
+		t.Fatalf("reserve %+v left after settlement", reserve)
+	}
+}
+
+func TestUnrevealedReserveCountsAsMet(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	l.setReservePrice(300, "pepper")
+	_, err := s.AddBid(l.ctx("seller"), "1", 100, 500, 10, 0, 0)
+	mustSucceed(t, err)
+	l.transient = nil
+	mustSucceed(t, s.Offer(l.ctx("alice"), 200, "1"))
+
+	l.advance(10 + SEALED_REVEAL_LIFETIME)
+	mustSucceed(t, s.TryEndBid(l.ctx("reader"), "1"))
+	if owner := l.owner("1"); owner != "seller" {
+		t.Fatal("settled within the reveal phase")
+	}
+	l.advance(1)
+	mustSucceed(t, s.TryEndBid(l.ctx("reader"), "1"))
+	if owner := l.owner("1"); owner != "alice" {
+		t.Fatalf("owner is %s", owner)
+	}
+	l.expectBalance("seller", 1200)
+}
+
+func TestKillPriceMeetsReserveWithoutReveal(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	l.setReservePrice(500, "pepper")
+	_, err := s.AddBid(l.ctx("seller"), "1", 100, 500, 10, 0, 0)
+	mustSucceed(t, err)
+	l.transient = nil
+	mustSucceed(t, s.Offer(l.ctx("alice"), 500, "1"))
+	mustSucceed(t, s.TryEndBid(l.ctx("reader"), "1"))
+	if owner := l.owner("1"); owner != "alice" {
+		t.Fatalf("owner is %s", owner)
+	}
+}
+
+func TestRelistAuctionReplacesReserve(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller")
+	l.seedNFT("1", "seller")
+	l.setReservePrice(300, "pepper")
+	_, err := s.AddBid(l.ctx("seller"), "1", 100, 500, 10, 0, 0)
+	mustSucceed(t, err)
+	l.advance(11)
+
+	_, err = s.RelistAuction(l.ctx("seller"), "1", 50, 200, 10)
+	mustFail(t, err, "reserve price must be between 50 and 200")
+	l.transient = nil
+	_, err = s.RelistAuction(l.ctx("seller"), "1", 50, 200, 10)
+	mustSucceed(t, err)
+	reserve, err := getReservePrice(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if reserve != nil {
+		t.Fatalf("reserve %+v kept after relisting without one", reserve)
+	}
+}
+
+func TestRevealReserveOnlyWhileSettlementWaits(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	l.seedNFT("2", "seller")
+	l.setReservePrice(300, "pepper")
+	for _, tokenID := range []string{"1", "2"} {
+		_, err := s.AddBid(l.ctx("seller"), tokenID, 100, 500, 10, 0, 0)
+		mustSucceed(t, err)
+	}
+	mustSucceed(t, s.Offer(l.ctx("alice"), 200, "2"))
+
+	l.advance(11)
+	// without a bid there is nothing to compare the reserve price with
+	_, err := s.RevealReserve(l.ctx("seller"), "1")
+	mustFail(t, err, "no reserve price waiting to be revealed")
+	l.advance(SEALED_REVEAL_LIFETIME)
+	_, err = s.RevealReserve(l.ctx("seller"), "2")
+	mustFail(t, err, "no reserve price waiting to be revealed")
+}
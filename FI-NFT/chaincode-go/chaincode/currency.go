@@ -0,0 +1,293 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// The platform currency is ERC-20 like, balances are the AccountBalance records under BalancePrefix.
+const AllowancePrefix = "owner~spender~allowance"
+const TotalSupplyPrefix = "currency~totalSupply"
+
+// CurrencyAllowance is the amount Spender may still move out of Owner's balance with TransferCurrencyFrom.
+type CurrencyAllowance struct {
+	Owner   string
+	Spender string
+	Amount  uint64
+}
+
+// MintCurrency creates amount of currency on the balance of account, only the admin can mint.
+func (s *SmartContract) MintCurrency(ctx contractapi.TransactionContextInterface, account string, amount uint64) error {
+	err := authorization(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to MintCurrency, not authenticated: %v\n", err)
+	}
+	_, err = credit(ctx, account, amount)
+	if err != nil {
+		return fmt.Errorf("failed to credit for MintCurrency: %v\n", err)
+	}
+	err = updateTotalSupply(ctx, amount, 0)
+	if err != nil {
+		return fmt.Errorf("failed to updateTotalSupply for MintCurrency: %v\n", err)
+	}
+	return emitEvent(ctx, CurrencyTransferEvent, &CurrencyTransfer{To: account, Amount: amount})
+}
+
+// BurnCurrency destroys amount of currency from the balance of account, only the admin can burn.
+func (s *SmartContract) BurnCurrency(ctx contractapi.TransactionContextInterface, account string, amount uint64) error {
+	err := authorization(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to BurnCurrency, not authenticated: %v\n", err)
+	}
+	err = updateTotalSupply(ctx, 0, amount)
+	if err != nil {
+		return fmt.Errorf("failed to updateTotalSupply for BurnCurrency: %v\n", err)
+	}
+	_, err = debit(ctx, account, amount)
+	if err != nil {
+		return fmt.Errorf("failed to debit for BurnCurrency: %v\n", err)
+	}
+	return emitEvent(ctx, CurrencyTransferEvent, &CurrencyTransfer{From: account, Amount: amount})
+}
+
+// TransferCurrency pays amount from the caller's balance to recipient, which must have an AccountBalance.
+func (s *SmartContract) TransferCurrency(ctx contractapi.TransactionContextInterface, recipient string, amount uint64) error {
+	sender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+	err = transferCurrency(ctx, sender, recipient, amount)
+	if err != nil {
+		return fmt.Errorf("failed to TransferCurrency: %v\n", err)
+	}
+	return nil
+}
+
+// ApproveCurrency lets spender move up to amount of the caller's currency with TransferCurrencyFrom,
+// replacing any previous allowance. It is the ERC-20 approve, Approve being the ERC-721 one.
+func (s *SmartContract) ApproveCurrency(ctx contractapi.TransactionContextInterface, spender string, amount uint64) error {
+	owner, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+	if spender == owner {
+		return fmt.Errorf("failed to ApproveCurrency, spender is the owner\n")
+	}
+	allowance := &CurrencyAllowance{Owner: owner, Spender: spender, Amount: amount}
+	err = putAllowance(ctx, allowance)
+	if err != nil {
+		return fmt.Errorf("failed to PutState for ApproveCurrency: %v\n", err)
+	}
+	return emitEvent(ctx, CurrencyApprovalEvent, allowance)
+}
+
+// Allowance returns the amount spender may still transfer from owner.
+func (s *SmartContract) Allowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (uint64, error) {
+	allowance, err := getAllowance(ctx, owner, spender)
+	if err != nil {
+		return 0, fmt.Errorf("failed to getAllowance for Allowance: %v\n", err)
+	}
+	return allowance.Amount, nil
+}
+
+// TransferCurrencyFrom pays amount from sender to recipient out of the allowance sender gave the caller.
+func (s *SmartContract) TransferCurrencyFrom(ctx contractapi.TransactionContextInterface, sender string, recipient string, amount uint64) error {
+	spender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+	allowance, err := getAllowance(ctx, sender, spender)
+	if err != nil {
+		return fmt.Errorf("failed to getAllowance for TransferCurrencyFrom: %v\n", err)
+	}
+	if allowance.Amount < amount {
+		return fmt.Errorf("failed to TransferCurrencyFrom, allowance %d lower than %d\n", allowance.Amount, amount)
+	}
+	err = transferCurrency(ctx, sender, recipient, amount)
+	if err != nil {
+		return fmt.Errorf("failed to TransferCurrencyFrom: %v\n", err)
+	}
+	allowance.Amount -= amount
+	err = putAllowance(ctx, allowance)
+	if err != nil {
+		return fmt.Errorf("failed to PutState for TransferCurrencyFrom: %v\n", err)
+	}
+	return nil
+}
+
+// TotalSupply returns the currency in circulation, including funds held for bids and deposits.
+// InitLedger seeds it from the currency already on the ledger, after that it counts what is minted,
+// burned, adjusted or opened by InitAccountBalance.
+func (s *SmartContract) TotalSupply(ctx contractapi.TransactionContextInterface) (uint64, error) {
+	return getTotalSupply(ctx)
+}
+
+func transferCurrency(ctx contractapi.TransactionContextInterface, sender string, recipient string, amount uint64) error {
+	_, err := getAccountBalance(ctx, recipient)
+	if err != nil {
+		return fmt.Errorf("failed to getAccountBalance of recipient: %v\n", err)
+	}
+	_, err = debit(ctx, sender, amount)
+	if err != nil {
+		return err
+	}
+	_, err = credit(ctx, recipient, amount)
+	if err != nil {
+		return err
+	}
+	return emitEvent(ctx, CurrencyTransferEvent, &CurrencyTransfer{From: sender, To: recipient, Amount: amount})
+}
+
+func getAllowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (*CurrencyAllowance, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(AllowancePrefix, []string{owner, spender})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+	}
+	allowance := &CurrencyAllowance{Owner: owner, Spender: spender}
+	if len(jvalue) == 0 {
+		return allowance, nil
+	}
+	err = json.Unmarshal(jvalue, allowance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data %v", err)
+	}
+	return allowance, nil
+}
+
+func putAllowance(ctx contractapi.TransactionContextInterface, allowance *CurrencyAllowance) error {
+	key, err := ctx.GetStub().CreateCompositeKey(AllowancePrefix, []string{allowance.Owner, allowance.Spender})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	if allowance.Amount == 0 {
+		return ctx.GetStub().DelState(key)
+	}
+	jvalue, err := json.Marshal(allowance)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data %v", err)
+	}
+	return ctx.GetStub().PutState(key, jvalue)
+}
+
+func getTotalSupply(ctx contractapi.TransactionContextInterface) (uint64, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(TotalSupplyPrefix, []string{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+	}
+	if len(jvalue) == 0 {
+		return 0, nil
+	}
+	var supply uint64
+	err = json.Unmarshal(jvalue, &supply)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unmarshal data %v", err)
+	}
+	return supply, nil
+}
+
+// updateTotalSupply adds minted and removes burned from the total supply. Burning more than the supply
+// fails, on a ledger with balances from before the supply was tracked InitLedger must seed it first.
+func updateTotalSupply(ctx contractapi.TransactionContextInterface, minted uint64, burned uint64) error {
+	supply, err := getTotalSupply(ctx)
+	if err != nil {
+		return err
+	}
+	if supply > math.MaxUint64-minted {
+		return &BalanceOverflowError{Account: TotalSupplyPrefix, Balance: supply, Amount: minted}
+	}
+	newSupply := supply + minted
+	if newSupply < burned {
+		return fmt.Errorf("burning %d exceeds total supply %d\n", burned, newSupply)
+	}
+	return putTotalSupply(ctx, newSupply-burned)
+}
+
+// seedTotalSupply sets the total supply to the currency on the ledger: every balance, the funds held
+// for top bids and the deposits of sealed bids.
+func seedTotalSupply(ctx contractapi.TransactionContextInterface) (uint64, error) {
+	var supply uint64
+	add := func(amount uint64) error {
+		if supply > math.MaxUint64-amount {
+			return &BalanceOverflowError{Account: TotalSupplyPrefix, Balance: supply, Amount: amount}
+		}
+		supply += amount
+		return nil
+	}
+	err := forEachState(ctx, BalancePrefix, func(jvalue []byte) error {
+		value := &AccountBalance{}
+		err := json.Unmarshal(jvalue, value)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal data %v", err)
+		}
+		return add(value.Balance)
+	})
+	if err != nil {
+		return 0, err
+	}
+	err = forEachState(ctx, EscrowPrefix, func(jvalue []byte) error {
+		value := &BidEscrow{}
+		err := json.Unmarshal(jvalue, value)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal data %v", err)
+		}
+		return add(value.Amount)
+	})
+	if err != nil {
+		return 0, err
+	}
+	err = forEachState(ctx, SealedBidPrefix, func(jvalue []byte) error {
+		value := &SealedBid{}
+		err := json.Unmarshal(jvalue, value)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal data %v", err)
+		}
+		return add(value.Deposit)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return supply, putTotalSupply(ctx, supply)
+}
+
+// forEachState calls fn with the value of every key under objectType.
+func forEachState(ctx contractapi.TransactionContextInterface, objectType string, fn func(jvalue []byte) error) error {
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to GetStateByPartialCompositeKey for %s: %v\n", objectType, err)
+	}
+	defer iter.Close()
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return fmt.Errorf("failed to iterate %s: %v\n", objectType, err)
+		}
+		err = fn(kv.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func putTotalSupply(ctx contractapi.TransactionContextInterface, supply uint64) error {
+	key, err := ctx.GetStub().CreateCompositeKey(TotalSupplyPrefix, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := json.Marshal(supply)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data %v", err)
+	}
+	return ctx.GetStub().PutState(key, jvalue)
+}
@@ -0,0 +1,108 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func (l *testLedger) expectSupply(want uint64) {
+	l.t.Helper()
+	supply, err := new(SmartContract).TotalSupply(l.ctx("reader"))
+	mustSucceed(l.t, err)
+	if supply != want {
+		l.t.Fatalf("total supply is %d, want %d", supply, want)
+	}
+}
+
+func TestMintAndBurnCurrency(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(100, "alice", "bob")
+	l.expectSupply(200)
+	mustFail(t, s.InitAccountBalance(l.adminCtx(), "alice", 50), "account already exists")
+
+	mustFail(t, s.MintCurrency(l.ctx("alice"), "alice", 10), "not authenticated")
+	mustFail(t, s.MintCurrency(l.adminCtx(), "nobody", 10), "failed to credit for MintCurrency")
+	mustSucceed(t, s.MintCurrency(l.adminCtx(), "alice", 10))
+	mustFail(t, s.BurnCurrency(l.ctx("bob"), "bob", 10), "not authenticated")
+	ctx := l.adminCtx()
+	mustSucceed(t, s.BurnCurrency(ctx, "bob", 20))
+	transfer := &CurrencyTransfer{}
+	mustSucceed(t, json.Unmarshal(ctx.events[len(ctx.events)-1].Payload, transfer))
+	if transfer.From != "bob" || transfer.To != "" || transfer.Amount != 20 {
+		t.Fatalf("burn event is %+v", transfer)
+	}
+	l.expectBalance("alice", 110)
+	l.expectBalance("bob", 80)
+	l.expectSupply(190)
+	mustFail(t, s.BurnCurrency(l.adminCtx(), "bob", 81), "insufficient funds on bob")
+}
+
+func TestTransferCurrencyWithAllowance(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(100, "alice", "bob", "carol")
+	mustFail(t, s.TransferCurrency(l.ctx("alice"), "bob", 101), "insufficient funds on alice")
+	mustFail(t, s.TransferCurrency(l.ctx("alice"), "nobody", 1), "failed to getAccountBalance of recipient")
+	mustSucceed(t, s.TransferCurrency(l.ctx("alice"), "bob", 10))
+	mustSucceed(t, s.TransferCurrency(l.ctx("alice"), "alice", 10))
+	l.expectBalance("alice", 90)
+	l.expectBalance("bob", 110)
+
+	mustSucceed(t, s.ApproveCurrency(l.ctx("bob"), "carol", 30))
+	allowance, err := s.Allowance(l.ctx("reader"), "bob", "carol")
+	mustSucceed(t, err)
+	if allowance != 30 {
+		t.Fatalf("allowance is %d", allowance)
+	}
+	mustFail(t, s.TransferCurrencyFrom(l.ctx("carol"), "bob", "alice", 31), "allowance 30 lower than 31")
+	mustFail(t, s.TransferCurrencyFrom(l.ctx("alice"), "bob", "alice", 1), "allowance 0 lower than 1")
+	mustSucceed(t, s.TransferCurrencyFrom(l.ctx("carol"), "bob", "alice", 20))
+	allowance, err = s.Allowance(l.ctx("reader"), "bob", "carol")
+	mustSucceed(t, err)
+	if allowance != 10 {
+		t.Fatalf("allowance after spending is %d", allowance)
+	}
+	l.expectBalance("alice", 110)
+	l.expectBalance("bob", 90)
+	l.expectSupply(300)
+}
+
+func TestBurnBeyondTrackedSupplyFailsUntilSeeded(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	// a balance opened before the supply was tracked
+	ctx := l.adminCtx()
+	key, err := ctx.GetStub().CreateCompositeKey(BalancePrefix, []string{"legacy"})
+	mustSucceed(t, err)
+	jvalue, err := json.Marshal(&AccountBalance{Account: "legacy", Balance: 5000})
+	mustSucceed(t, err)
+	mustSucceed(t, ctx.GetStub().PutState(key, jvalue))
+
+	l.openAccounts(1000, "seller", "alice", "bob")
+	l.seedNFT("1", "seller")
+	l.seedNFT("2", "seller")
+	_, err = s.AddBid(l.ctx("seller"), "1", 100, 900, 10, 0, 0)
+	mustSucceed(t, err)
+	mustSucceed(t, s.Offer(l.ctx("alice"), 200, "1"))
+	_, err = s.AddAuction(l.ctx("seller"), "2", AuctionSealedBid, 10, 0, 10)
+	mustSucceed(t, err)
+	l.setSealedBid(50, "pepper")
+	mustSucceed(t, s.CommitBid(l.ctx("bob"), "2", SealedBidHash("2", "bob", 50, "pepper"), 100))
+	l.transient = nil
+	l.expectSupply(3000)
+
+	mustFail(t, s.BurnCurrency(l.adminCtx(), "legacy", 4000), "burning 4000 exceeds total supply 3000")
+	_, err = s.AdjustBalance(l.adminCtx(), "legacy", -4000, "clawback")
+	mustFail(t, err, "exceeds total supply")
+	l.expectBalance("legacy", 5000)
+
+	// the legacy balance, the held bid of alice and the deposit of bob are all counted
+	l.openAccounts(0, "treasury")
+	_, err = s.InitLedger(l.adminCtx(), "treasury")
+	mustSucceed(t, err)
+	l.expectSupply(8000)
+	mustSucceed(t, s.BurnCurrency(l.adminCtx(), "legacy", 4000))
+	l.expectSupply(4000)
+	l.expectBalance("legacy", 1000)
+}
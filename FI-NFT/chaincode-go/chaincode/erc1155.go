@@ -0,0 +1,234 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// A token class is an NFT record with a non-zero Supply: its metadata and CID are shared by Supply
+// editions, whose holders are kept under EditionPrefix instead of NFT.Owner.
+const EditionPrefix = "tokenID~owner~editions"
+
+// LOT_SEPARATOR joins a token class and the seller of an edition lot into its lot ID, minted tokenIDs cannot contain it.
+const LOT_SEPARATOR = "#"
+
+// EditionBalance is the number of editions of the token class TokenID held by Owner.
+type EditionBalance struct {
+	TokenID string
+	Owner   string
+	Amount  uint64
+}
+
+// MintEditions mints supply editions of the content cid as the token class tokenID, all held by the caller,
+// see MintWithCID. The mint fee is charged once for the class, and the creator royalty applies to every edition sold.
+func (s *SmartContract) MintEditions(ctx contractapi.TransactionContextInterface, tokenID string, cid string, ftype string, size uint64, royaltyBasisPoints uint64, supply uint64) (*NFT, error) {
+	if supply == 0 {
+		return nil, fmt.Errorf("failed to MintEditions, supply must be at least 1\n")
+	}
+	value := &NFT{
+		ID:                 tokenID,
+		CID:                cid,
+		FileType:           ftype,
+		Size:               size,
+		RoyaltyBasisPoints: royaltyBasisPoints,
+		Supply:             supply,
+	}
+	err := mintCID(ctx, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to MintEditions: %v\n", err)
+	}
+	return value, nil
+}
+
+// BalanceOfBatch returns the number of tokenIDs[i] held by accounts[i]: 0 or 1 for a unique NFT, the
+// editions held for a token class. Editions listed on an auction are not counted until it ends.
+func (s *SmartContract) BalanceOfBatch(ctx contractapi.TransactionContextInterface, accounts []string, tokenIDs []string) ([]uint64, error) {
+	if len(accounts) != len(tokenIDs) {
+		return nil, fmt.Errorf("failed to BalanceOfBatch, %d accounts for %d tokenIDs\n", len(accounts), len(tokenIDs))
+	}
+	balances := make([]uint64, len(accounts))
+	for i, tokenID := range tokenIDs {
+		nft, err := getNFT(ctx, tokenID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to getNFT %s for BalanceOfBatch: %v\n", tokenID, err)
+		}
+		if nft.Supply == 0 {
+			if nft.Owner == accounts[i] {
+				balances[i] = 1
+			}
+			continue
+		}
+		editions, err := getEditionBalance(ctx, tokenID, accounts[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to getEditionBalance for BalanceOfBatch: %v\n", err)
+		}
+		balances[i] = editions.Amount
+	}
+	return balances, nil
+}
+
+// TransferBatch moves amounts[i] of tokenIDs[i] from from to to. The caller must be from or one of its
+// operators. A unique NFT in the batch moves as with TransferFrom and its amount must be 1.
+func (s *SmartContract) TransferBatch(ctx contractapi.TransactionContextInterface, from string, to string, tokenIDs []string, amounts []uint64) error {
+	operator, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+	if len(tokenIDs) == 0 || len(tokenIDs) != len(amounts) {
+		return fmt.Errorf("failed to TransferBatch, %d tokenIDs for %d amounts\n", len(tokenIDs), len(amounts))
+	}
+	if to == "" || to == from {
+		return fmt.Errorf("failed to TransferBatch, invalid recipient\n")
+	}
+	if operator != from {
+		isOperator, err := isApprovedForAll(ctx, from, operator)
+		if err != nil {
+			return fmt.Errorf("failed to check operator for TransferBatch: %v\n", err)
+		}
+		if !isOperator {
+			return fmt.Errorf("failed to TransferBatch, caller is not from nor approved for all\n")
+		}
+	}
+
+	//editions are read from the ledger, which does not show the writes of this transaction
+	seen := map[string]bool{}
+	for i, tokenID := range tokenIDs {
+		if seen[tokenID] {
+			return fmt.Errorf("failed to TransferBatch, tokenID %s appears twice\n", tokenID)
+		}
+		seen[tokenID] = true
+		if amounts[i] == 0 {
+			return fmt.Errorf("failed to TransferBatch, zero amount of %s\n", tokenID)
+		}
+	}
+
+	event := &EditionsTransferred{Operator: operator, From: from, To: to}
+	for i, tokenID := range tokenIDs {
+		nft, err := getNFT(ctx, tokenID)
+		if err != nil {
+			return fmt.Errorf("failed to getNFT %s for TransferBatch: %v\n", tokenID, err)
+		}
+		if nft.Supply == 0 {
+			if amounts[i] != 1 {
+				return fmt.Errorf("failed to TransferBatch, %s is a unique token\n", tokenID)
+			}
+			err = transferFrom(ctx, from, to, tokenID)
+			if err != nil {
+				return fmt.Errorf("failed to TransferBatch: %v\n", err)
+			}
+			continue
+		}
+		err = moveEditions(ctx, tokenID, from, to, amounts[i])
+		if err != nil {
+			return fmt.Errorf("failed to TransferBatch: %v\n", err)
+		}
+		event.TokenIDs = append(event.TokenIDs, tokenID)
+		event.Amounts = append(event.Amounts, amounts[i])
+	}
+	if len(event.TokenIDs) == 0 {
+		return nil
+	}
+	return emitEvent(ctx, EditionsTransferredEvent, event)
+}
+
+// AddEditionAuction sells quantity editions of the token class tokenID as one lot, see AddAuction.
+// The editions are taken out of the caller's balance until the auction is settled or cancelled.
+// The lot is auctioned under its lot ID, the TokenID of the returned NFTBid, which the other auction
+// transactions take in place of a tokenID.
+func (s *SmartContract) AddEditionAuction(ctx contractapi.TransactionContextInterface, tokenID string, quantity uint64, auctionType string, lowerPrice uint64, upPrice uint64, lifeMinute uint64) (*NFTBid, error) {
+	if quantity == 0 {
+		return nil, fmt.Errorf("failed to AddEditionAuction, quantity must be at least 1\n")
+	}
+	return addAuction(ctx, tokenID, auctionType, lowerPrice, upPrice, lifeMinute, quantity)
+}
+
+// editionLotID is the lot ID of the editions of tokenID listed by seller. Each holder of a class can
+// list one lot of it at a time.
+func editionLotID(tokenID string, seller string) string {
+	return tokenID + LOT_SEPARATOR + seller
+}
+
+// moveEditions moves amount editions of tokenID from from to to, from and to must differ.
+func moveEditions(ctx contractapi.TransactionContextInterface, tokenID string, from string, to string, amount uint64) error {
+	err := debitEditions(ctx, tokenID, from, amount)
+	if err != nil {
+		return err
+	}
+	return creditEditions(ctx, tokenID, to, amount)
+}
+
+// creditEditions adds amount editions of tokenID to owner, indexing the class under owner on its first edition.
+func creditEditions(ctx contractapi.TransactionContextInterface, tokenID string, owner string, amount uint64) error {
+	editions, err := getEditionBalance(ctx, tokenID, owner)
+	if err != nil {
+		return err
+	}
+	if editions.Amount > math.MaxUint64-amount {
+		return fmt.Errorf("editions overflow on %s for %s\n", tokenID, owner)
+	}
+	if editions.Amount == 0 {
+		err = addNFTToList(ctx, owner, tokenID)
+		if err != nil {
+			return fmt.Errorf("failed to addNFTToList: %v\n", err)
+		}
+	}
+	editions.Amount += amount
+	return putEditionBalance(ctx, editions)
+}
+
+// debitEditions takes amount editions of tokenID from owner, removing the index entry with its last edition.
+func debitEditions(ctx contractapi.TransactionContextInterface, tokenID string, owner string, amount uint64) error {
+	editions, err := getEditionBalance(ctx, tokenID, owner)
+	if err != nil {
+		return err
+	}
+	if editions.Amount < amount {
+		return fmt.Errorf("not enough editions of %s, has: %d, need: %d\n", tokenID, editions.Amount, amount)
+	}
+	editions.Amount -= amount
+	if editions.Amount == 0 {
+		err = removeNFTFromList(ctx, tokenID, owner)
+		if err != nil {
+			return err
+		}
+	}
+	return putEditionBalance(ctx, editions)
+}
+
+func getEditionBalance(ctx contractapi.TransactionContextInterface, tokenID string, owner string) (*EditionBalance, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(EditionPrefix, []string{tokenID, owner})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+	}
+	editions := &EditionBalance{TokenID: tokenID, Owner: owner}
+	if len(jvalue) == 0 {
+		return editions, nil
+	}
+	err = json.Unmarshal(jvalue, editions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data %v", err)
+	}
+	return editions, nil
+}
+
+func putEditionBalance(ctx contractapi.TransactionContextInterface, editions *EditionBalance) error {
+	key, err := ctx.GetStub().CreateCompositeKey(EditionPrefix, []string{editions.TokenID, editions.Owner})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	if editions.Amount == 0 {
+		return ctx.GetStub().DelState(key)
+	}
+	jvalue, err := json.Marshal(editions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data %v", err)
+	}
+	return ctx.GetStub().PutState(key, jvalue)
+}
@@ -0,0 +1,114 @@
+package chaincode
+
+import "testing"
+
+func (l *testLedger) expectEditions(accounts []string, tokenIDs []string, want ...uint64) {
+	l.t.Helper()
+	balances, err := new(SmartContract).BalanceOfBatch(l.ctx("reader"), accounts, tokenIDs)
+	mustSucceed(l.t, err)
+	for i := range want {
+		if balances[i] != want[i] {
+			l.t.Fatalf("%s holds %d of %s, want %d", accounts[i], balances[i], tokenIDs[i], want[i])
+		}
+	}
+}
+
+func TestMintEditionsAndTransferBatch(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openTreasury()
+	l.openAccounts(1000, "creator", "holder")
+	_, err := s.MintEditions(l.ctx("creator"), "class", testCID, "png", 100, 500, 0)
+	mustFail(t, err, "supply must be at least 1")
+	class, err := s.MintEditions(l.ctx("creator"), "class", testCID, "png", 100, 500, 10)
+	mustSucceed(t, err)
+	if class.Supply != 10 || class.Owner != "" || class.Creator != "creator" {
+		t.Fatalf("minted %+v", class)
+	}
+	l.expectBalance("creator", 1000-MINT_FEE)
+	l.seedNFT("unique", "creator")
+	l.expectEditions([]string{"creator", "holder", "creator"}, []string{"class", "class", "unique"}, 10, 0, 1)
+	_, err = s.BalanceOfBatch(l.ctx("reader"), []string{"creator"}, []string{"class", "unique"})
+	mustFail(t, err, "1 accounts for 2 tokenIDs")
+
+	mustFail(t, s.TransferBatch(l.ctx("holder"), "creator", "holder", []string{"class"}, []uint64{1}), "not from nor approved for all")
+	mustFail(t, s.TransferBatch(l.ctx("creator"), "creator", "holder", []string{"class", "class"}, []uint64{1, 1}), "appears twice")
+	mustFail(t, s.TransferBatch(l.ctx("creator"), "creator", "holder", []string{"class"}, []uint64{0}), "zero amount")
+	mustFail(t, s.TransferBatch(l.ctx("creator"), "creator", "holder", []string{"unique"}, []uint64{2}), "is a unique token")
+	mustFail(t, s.TransferBatch(l.ctx("creator"), "creator", "holder", []string{"class"}, []uint64{11}), "not enough editions of class")
+	mustFail(t, s.TransferNFT(l.ctx("creator"), "holder", "class"), "is a token class, use TransferBatch")
+
+	ctx := l.ctx("creator")
+	mustSucceed(t, s.TransferBatch(ctx, "creator", "holder", []string{"class", "unique"}, []uint64{4, 1}))
+	if names := eventNames(ctx); names[len(names)-1] != EditionsTransferredEvent {
+		t.Fatalf("events are %v", names)
+	}
+	l.expectEditions([]string{"creator", "holder", "holder"}, []string{"class", "class", "unique"}, 6, 4, 1)
+	held, err := s.BalanceOf(l.ctx("reader"), "holder")
+	mustSucceed(t, err)
+	if held != 2 {
+		t.Fatalf("holder holds %d tokens", held)
+	}
+}
+
+func TestEditionAuctionSellsALot(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openTreasury()
+	l.openAccounts(1000, "creator", "holder", "buyer")
+	_, err := s.MintEditions(l.ctx("creator"), "class", testCID, "png", 100, 1000, 10)
+	mustSucceed(t, err)
+	mustSucceed(t, s.TransferBatch(l.ctx("creator"), "creator", "holder", []string{"class"}, []uint64{4}))
+
+	_, err = s.AddAuction(l.ctx("holder"), "class", AuctionFixedPrice, 0, 200, 10)
+	mustFail(t, err, "use AddEditionAuction")
+	_, err = s.AddEditionAuction(l.ctx("holder"), "class", 0, AuctionFixedPrice, 0, 200, 10)
+	mustFail(t, err, "quantity must be at least 1")
+	_, err = s.AddEditionAuction(l.ctx("holder"), "class", 5, AuctionFixedPrice, 0, 200, 10)
+	mustFail(t, err, "not enough editions of class, has: 4, need: 5")
+	bid, err := s.AddEditionAuction(l.ctx("holder"), "class", 4, AuctionFixedPrice, 0, 200, 10)
+	mustSucceed(t, err)
+	if bid.TokenID != "class#holder" || bid.ClassID != "class" || bid.Quantity != 4 || bid.Seller != "holder" {
+		t.Fatalf("lot is %+v", bid)
+	}
+	// the listed editions are held by the auction
+	l.expectEditions([]string{"holder"}, []string{"class"}, 0)
+	_, err = s.AddEditionAuction(l.ctx("holder"), "class", 1, AuctionFixedPrice, 0, 200, 10)
+	mustFail(t, err, "Bid already exists")
+	// every holder lists its own lot
+	other, err := s.AddEditionAuction(l.ctx("creator"), "class", 2, AuctionFixedPrice, 0, 150, 10)
+	mustSucceed(t, err)
+	if other.TokenID != "class#creator" {
+		t.Fatalf("second lot is %+v", other)
+	}
+	_, err = s.MintWithCID(l.ctx("creator"), "class#buyer", testCID, "png", 100, 0)
+	mustFail(t, err, "reserved for lot IDs")
+
+	mustFail(t, s.Offer(l.ctx("buyer"), 200, "class"), "Bid not exist")
+	mustSucceed(t, s.Offer(l.ctx("buyer"), 200, bid.TokenID))
+	mustSucceed(t, s.TryEndBid(l.ctx("reader"), bid.TokenID))
+	l.expectEditions([]string{"holder", "buyer", "creator"}, []string{"class", "class", "class"}, 0, 4, 4)
+	// the creator royalty is 10% of the lot
+	l.expectBalance("creator", 1000-MINT_FEE+20)
+	l.expectBalance("holder", 1180)
+	l.expectBalance("buyer", 800)
+
+	mustSucceed(t, s.Offer(l.ctx("buyer"), 150, other.TokenID))
+	mustSucceed(t, s.TryEndBid(l.ctx("reader"), other.TokenID))
+	l.expectEditions([]string{"buyer", "creator"}, []string{"class", "class"}, 6, 4)
+	l.expectBalance("creator", 1000-MINT_FEE+20+150)
+}
+
+func TestCancelEditionAuctionReturnsTheLot(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openTreasury()
+	l.openAccounts(1000, "creator", "bidder")
+	_, err := s.MintEditions(l.ctx("creator"), "class", testCID, "png", 100, 0, 10)
+	mustSucceed(t, err)
+	bid, err := s.AddEditionAuction(l.ctx("creator"), "class", 6, AuctionEnglish, 10, 200, 10)
+	mustSucceed(t, err)
+	l.expectEditions([]string{"creator"}, []string{"class"}, 4)
+	mustSucceed(t, s.CancelAuction(l.ctx("creator"), bid.TokenID))
+	l.expectEditions([]string{"creator"}, []string{"class"}, 10)
+}
@@ -0,0 +1,208 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const OperatorApprovalPrefix = "owner~operator~approved"
+
+// OperatorApproval records that Operator may transfer every token of Owner.
+type OperatorApproval struct {
+	Owner    string
+	Operator string
+	Approved bool
+}
+
+// OwnerOf returns the account owning tokenID.
+func (s *SmartContract) OwnerOf(ctx contractapi.TransactionContextInterface, tokenID string) (string, error) {
+	nft, err := getNFT(ctx, tokenID)
+	if err != nil {
+		return "", fmt.Errorf("failed to getNFT for OwnerOf: %v\n", err)
+	}
+	return nft.Owner, nil
+}
+
+// BalanceOf returns the number of tokens owned by owner.
+func (s *SmartContract) BalanceOf(ctx contractapi.TransactionContextInterface, owner string) (int, error) {
+	tokenIDs, err := getNFTList(ctx, owner)
+	if err != nil {
+		return 0, fmt.Errorf("failed to getNFTList for BalanceOf: %v\n", err)
+	}
+	return len(tokenIDs), nil
+}
+
+// Approve lets approved transfer tokenID on behalf of its owner, an empty approved clears the approval.
+// Only the owner or one of the owner's operators can approve.
+func (s *SmartContract) Approve(ctx contractapi.TransactionContextInterface, approved string, tokenID string) error {
+	operator, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+	nft, err := getNFT(ctx, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to getNFT for Approve: %v\n", err)
+	}
+	if approved == nft.Owner {
+		return fmt.Errorf("failed to Approve, approval to current owner\n")
+	}
+	if operator != nft.Owner {
+		isOperator, err := isApprovedForAll(ctx, nft.Owner, operator)
+		if err != nil {
+			return fmt.Errorf("failed to check operator for Approve: %v\n", err)
+		}
+		if !isOperator {
+			return fmt.Errorf("failed to Approve, caller is not owner nor approved for all\n")
+		}
+	}
+
+	nft.Approved = approved
+	err = putNFT(ctx, nft)
+	if err != nil {
+		return fmt.Errorf("failed to PutState for Approve: %v\n", err)
+	}
+	return emitEvent(ctx, ApprovalEvent, &Approval{TokenID: tokenID, Owner: nft.Owner, Approved: approved})
+}
+
+// GetApproved returns the account approved for tokenID, or an empty string if there is none.
+func (s *SmartContract) GetApproved(ctx contractapi.TransactionContextInterface, tokenID string) (string, error) {
+	nft, err := getNFT(ctx, tokenID)
+	if err != nil {
+		return "", fmt.Errorf("failed to getNFT for GetApproved: %v\n", err)
+	}
+	return nft.Approved, nil
+}
+
+// SetApprovalForAll lets operator transfer and approve every token of the caller, now and in the future.
+func (s *SmartContract) SetApprovalForAll(ctx contractapi.TransactionContextInterface, operator string, approved bool) error {
+	owner, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+	if operator == owner {
+		return fmt.Errorf("failed to SetApprovalForAll, approve to caller\n")
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(OperatorApprovalPrefix, []string{owner, operator})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	value := &OperatorApproval{
+		Owner:    owner,
+		Operator: operator,
+		Approved: approved,
+	}
+	jvalue, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data %v", err)
+	}
+	err = ctx.GetStub().PutState(key, jvalue)
+	if err != nil {
+		return fmt.Errorf("failed to PutState for SetApprovalForAll: %v\n", err)
+	}
+	return emitEvent(ctx, ApprovalForAllEvent, value)
+}
+
+func (s *SmartContract) IsApprovedForAll(ctx contractapi.TransactionContextInterface, owner string, operator string) (bool, error) {
+	return isApprovedForAll(ctx, owner, operator)
+}
+
+// TransferFrom moves tokenID from its owner to to. The caller must be the owner, the approved
+// account of the token or an operator of the owner, and the token must not be on sale.
+func (s *SmartContract) TransferFrom(ctx contractapi.TransactionContextInterface, from string, to string, tokenID string) error {
+	return transferFrom(ctx, from, to, tokenID)
+}
+
+// SafeTransferFrom is TransferFrom that also requires to to be a registered account,
+// so a token cannot be sent to a mistyped client id.
+func (s *SmartContract) SafeTransferFrom(ctx contractapi.TransactionContextInterface, from string, to string, tokenID string) error {
+	_, err := getAccountBalance(ctx, to)
+	if err != nil {
+		return fmt.Errorf("failed to SafeTransferFrom, recipient is not a registered account: %v\n", err)
+	}
+	return transferFrom(ctx, from, to, tokenID)
+}
+
+func transferFrom(ctx contractapi.TransactionContextInterface, from string, to string, tokenID string) error {
+	operator, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+	if to == "" {
+		return fmt.Errorf("failed to TransferFrom, transfer to empty account\n")
+	}
+	nft, err := getNFT(ctx, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to getNFT for TransferFrom: %v\n", err)
+	}
+	if nft.Supply > 0 {
+		return fmt.Errorf("failed to TransferFrom, token %s is a token class, use TransferBatch\n", tokenID)
+	}
+	if nft.Owner != from {
+		return fmt.Errorf("failed to TransferFrom, token %s is not owned by from\n", tokenID)
+	}
+	allowed, err := isApprovedOrOwner(ctx, nft, operator)
+	if err != nil {
+		return fmt.Errorf("failed to check approval for TransferFrom: %v\n", err)
+	}
+	if !allowed {
+		return fmt.Errorf("failed to TransferFrom, caller is not owner nor approved\n")
+	}
+	onSale, err := bidExists(ctx, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to check bid for TransferFrom: %v\n", err)
+	}
+	if onSale {
+		return fmt.Errorf("failed to TransferFrom, token %s is on sale\n", tokenID)
+	}
+	return transferNFT(ctx, nft, to)
+}
+
+// transferNFT changes the owner of nft to to, keeping both owners' index entries in step
+// and clearing the token approval. Callers check permissions and auctions.
+func transferNFT(ctx contractapi.TransactionContextInterface, nft *NFT, to string) error {
+	from := nft.Owner
+	err := removeNFTFromList(ctx, nft.ID, from)
+	if err != nil {
+		return fmt.Errorf("failed to remove nft from old owner's list: %v\n", err)
+	}
+	err = addNFTToList(ctx, to, nft.ID)
+	if err != nil {
+		return fmt.Errorf("failed to add nft to new owner's list: %v\n", err)
+	}
+	nft.Owner = to
+	nft.Approved = ""
+	err = putNFT(ctx, nft)
+	if err != nil {
+		return fmt.Errorf("failed to PutState for transferNFT: %v\n", err)
+	}
+	return emitEvent(ctx, NFTTransferredEvent, &NFTTransferred{TokenID: nft.ID, From: from, To: to})
+}
+
+func isApprovedOrOwner(ctx contractapi.TransactionContextInterface, nft *NFT, operator string) (bool, error) {
+	if operator == nft.Owner || (nft.Approved != "" && operator == nft.Approved) {
+		return true, nil
+	}
+	return isApprovedForAll(ctx, nft.Owner, operator)
+}
+
+func isApprovedForAll(ctx contractapi.TransactionContextInterface, owner string, operator string) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(OperatorApprovalPrefix, []string{owner, operator})
+	if err != nil {
+		return false, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+	}
+	if len(jvalue) == 0 {
+		return false, nil
+	}
+	value := &OperatorApproval{}
+	err = json.Unmarshal(jvalue, value)
+	if err != nil {
+		return false, fmt.Errorf("failed to unmarshal data %v", err)
+	}
+	return value.Approved, nil
+}
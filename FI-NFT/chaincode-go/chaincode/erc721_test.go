@@ -0,0 +1,79 @@
+package chaincode
+
+import "testing"
+
+func TestApproveAllowsOneTransfer(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.seedNFT("1", "alice")
+
+	mustFail(t, s.Approve(l.ctx("bob"), "bob", "1"), "not owner nor approved for all")
+	mustFail(t, s.Approve(l.ctx("alice"), "alice", "1"), "approval to current owner")
+	mustSucceed(t, s.Approve(l.ctx("alice"), "bob", "1"))
+	approved, err := s.GetApproved(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if approved != "bob" {
+		t.Fatalf("approved is %s", approved)
+	}
+
+	mustFail(t, s.TransferFrom(l.ctx("carol"), "alice", "carol", "1"), "not owner nor approved")
+	mustFail(t, s.TransferFrom(l.ctx("bob"), "carol", "bob", "1"), "not owned by from")
+	mustSucceed(t, s.TransferFrom(l.ctx("bob"), "alice", "carol", "1"))
+	if owner := l.owner("1"); owner != "carol" {
+		t.Fatalf("owner is %s", owner)
+	}
+	// the approval is cleared by the transfer
+	approved, err = s.GetApproved(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if approved != "" {
+		t.Fatalf("approval %s kept after transfer", approved)
+	}
+	mustFail(t, s.TransferFrom(l.ctx("bob"), "carol", "bob", "1"), "not owner nor approved")
+}
+
+func TestOperatorTransfersAndApprovesEveryToken(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.seedNFT("1", "alice")
+	l.seedNFT("2", "alice")
+
+	mustFail(t, s.SetApprovalForAll(l.ctx("alice"), "alice", true), "approve to caller")
+	mustSucceed(t, s.SetApprovalForAll(l.ctx("alice"), "operator", true))
+	isOperator, err := s.IsApprovedForAll(l.ctx("reader"), "alice", "operator")
+	mustSucceed(t, err)
+	if !isOperator {
+		t.Fatal("operator not approved")
+	}
+	mustSucceed(t, s.Approve(l.ctx("operator"), "bob", "2"))
+	mustSucceed(t, s.TransferFrom(l.ctx("operator"), "alice", "carol", "1"))
+	balance, err := s.BalanceOf(l.ctx("reader"), "carol")
+	mustSucceed(t, err)
+	if balance != 1 {
+		t.Fatalf("carol holds %d tokens", balance)
+	}
+
+	mustSucceed(t, s.SetApprovalForAll(l.ctx("alice"), "operator", false))
+	mustFail(t, s.TransferFrom(l.ctx("operator"), "alice", "carol", "2"), "not owner nor approved")
+}
+
+func TestTransferFromRejectsTokenOnSale(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "alice", "bob")
+	l.seedNFT("1", "alice")
+	_, err := s.AddAuction(l.ctx("alice"), "1", AuctionFixedPrice, 0, 100, 10)
+	mustSucceed(t, err)
+	mustFail(t, s.TransferFrom(l.ctx("alice"), "alice", "bob", "1"), "on sale")
+}
+
+func TestSafeTransferFromNeedsRegisteredRecipient(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(0, "bob")
+	l.seedNFT("1", "alice")
+	mustFail(t, s.SafeTransferFrom(l.ctx("alice"), "alice", "b0b", "1"), "not a registered account")
+	mustSucceed(t, s.SafeTransferFrom(l.ctx("alice"), "alice", "bob", "1"))
+	if owner := l.owner("1"); owner != "bob" {
+		t.Fatalf("owner is %s", owner)
+	}
+}
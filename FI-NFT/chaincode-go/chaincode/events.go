@@ -0,0 +1,201 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Chaincode event names, see EVENTS.md for the payload of each one.
+// Fabric keeps a single event per transaction, so a transaction that raises more than one
+// event (e.g. a bid that refunds the outbid bidder) emits them together as BatchEvent.
+const (
+	NFTMintedEvent           = "NFTMinted"
+	ContentAttestedEvent     = "ContentAttested"
+	NFTTransferredEvent      = "NFTTransferred"
+	EditionsTransferredEvent = "EditionsTransferred"
+	AuctionCreatedEvent      = "AuctionCreated"
+	AuctionRelistedEvent     = "AuctionRelisted" // payload is an AuctionCreated
+	BidPlacedEvent           = "BidPlaced"
+	BidCommittedEvent        = "BidCommitted"
+	BidRevealedEvent         = "BidRevealed"
+	AuctionExtendedEvent     = "AuctionExtended"
+	AuctionSettledEvent      = "AuctionSettled"
+	AuctionCancelledEvent    = "AuctionCancelled"
+	BalanceChangedEvent      = "BalanceChanged"
+	AccountRegisteredEvent   = "AccountRegistered" // payload is an AccountInfo
+	BalanceAdjustedEvent     = "BalanceAdjusted"   // payload is a BalanceAdjustment
+	ConfigChangedEvent       = "ConfigChanged"     // payload is a Config
+	CurrencyTransferEvent    = "CurrencyTransfer"
+	CurrencyApprovalEvent    = "CurrencyApproval" // payload is a CurrencyAllowance
+	ApprovalEvent            = "Approval"
+	ApprovalForAllEvent      = "ApprovalForAll" // payload is an OperatorApproval
+	AccessGrantedEvent       = "AccessGranted"  // payload is an AccessGrant
+	AccessRevokedEvent       = "AccessRevoked"  // payload is the revoked AccessGrant
+	BatchEvent               = "Batch"
+)
+
+type NFTMinted struct {
+	TokenID            string
+	CID                string
+	Owner              string
+	FileType           string
+	Size               uint64
+	RoyaltyBasisPoints uint64
+	Supply             uint64
+	MetadataCID        string
+}
+
+// ContentAttested is raised when an off-chain verifier confirms that the content of CID is on IPFS.
+type ContentAttested struct {
+	TokenID    string
+	CID        string
+	Verifier   string
+	VerifyTime uint64
+}
+
+type NFTTransferred struct {
+	TokenID string
+	From    string
+	To      string
+}
+
+// EditionsTransferred is raised by TransferBatch for the editions of token classes, Amounts[i] of TokenIDs[i].
+type EditionsTransferred struct {
+	Operator string
+	From     string
+	To       string
+	TokenIDs []string
+	Amounts  []uint64
+}
+
+// AuctionCreated is raised for a new listing. For an edition lot, TokenID is the lot ID, ClassID the token class
+// and Quantity the number of editions in the lot.
+type AuctionCreated struct {
+	TokenID     string
+	ClassID     string `json:",omitempty"`
+	AuctionType string
+	Seller      string
+	LowerPrice  uint64
+	KillPrice   uint64
+	CreateTime  uint64
+	LifeTime    uint64
+	Quantity    uint64
+}
+
+// BidPlaced is raised for every accepted bid, PreviousBidder is the outbid account
+// (NonBidder for the first bid) whose held funds were refunded.
+type BidPlaced struct {
+	TokenID        string
+	Bidder         string
+	Price          uint64
+	PreviousBidder string
+	PreviousPrice  uint64
+}
+
+// BidCommitted is raised by CommitBid, the bid amount itself stays hidden until BidRevealed.
+type BidCommitted struct {
+	TokenID string
+	Bidder  string
+	Deposit uint64
+}
+
+type BidRevealed struct {
+	TokenID string
+	Bidder  string
+	Amount  uint64
+}
+
+// AuctionExtended is raised when a late bid pushes the end of an auction back, EndTime is the new end.
+type AuctionExtended struct {
+	TokenID  string
+	EndTime  uint64
+	Extended uint64
+}
+
+// AuctionSettled is raised when the token is sold, Royalty and Commission are the parts of Price
+// paid to the creator and the treasury. Quantity is the number of editions sold, 0 for a unique NFT.
+type AuctionSettled struct {
+	TokenID    string
+	ClassID    string `json:",omitempty"`
+	Seller     string
+	Winner     string
+	Price      uint64
+	Royalty    uint64
+	Commission uint64
+	Quantity   uint64
+}
+
+type AuctionCancelled struct {
+	TokenID string
+	Seller  string
+	Reason  string
+}
+
+type BalanceChanged struct {
+	Account string
+	Before  uint64
+	After   uint64
+}
+
+// CurrencyTransfer is raised when currency is minted (empty From), burned (empty To) or transferred.
+type CurrencyTransfer struct {
+	From   string
+	To     string
+	Amount uint64
+}
+
+type Approval struct {
+	TokenID  string
+	Owner    string
+	Approved string
+}
+
+// ChaincodeEvent is one entry of a BatchEvent payload.
+type ChaincodeEvent struct {
+	Name    string
+	Payload json.RawMessage
+}
+
+// TransactionContext collects the events raised during a transaction so they can be
+// emitted together by EmitEvents once the transaction function returns successfully.
+type TransactionContext struct {
+	contractapi.TransactionContext
+	events []ChaincodeEvent
+	// balances written by the transaction, see putAccountBalance
+	balances map[string]AccountBalance
+}
+
+// emitEvent queues an event for the current transaction. Without a TransactionContext
+// the event is set on the stub directly, replacing any earlier event of the transaction.
+func emitEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+	jvalue, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v\n", name, err)
+	}
+	tc, ok := ctx.(*TransactionContext)
+	if !ok {
+		return ctx.GetStub().SetEvent(name, jvalue)
+	}
+	tc.events = append(tc.events, ChaincodeEvent{Name: name, Payload: jvalue})
+	return nil
+}
+
+// EmitEvents is the AfterTransaction hook that sets the events queued by emitEvent on the stub.
+func EmitEvents(ctx contractapi.TransactionContextInterface) error {
+	tc, ok := ctx.(*TransactionContext)
+	if !ok || len(tc.events) == 0 {
+		return nil
+	}
+	events := tc.events
+	tc.events = nil
+	if len(events) == 1 {
+		return ctx.GetStub().SetEvent(events[0].Name, events[0].Payload)
+	}
+	jvalue, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v\n", BatchEvent, err)
+	}
+	return ctx.GetStub().SetEvent(BatchEvent, jvalue)
+}
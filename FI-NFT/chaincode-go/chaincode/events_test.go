@@ -0,0 +1,67 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEmitEventsBatchesEventsOfOneTransaction(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice", "bob")
+	l.seedNFT("1", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", AuctionEnglish, 100, 1000, 10)
+	mustSucceed(t, err)
+	mustSucceed(t, s.Offer(l.ctx("alice"), 200, "1"))
+
+	ctx := l.ctx("bob")
+	mustSucceed(t, s.Offer(ctx, 300, "1"))
+	names := strings.Join(eventNames(ctx), ",")
+	if names != "BalanceChanged,BalanceChanged,BidPlaced" {
+		t.Fatalf("outbid raised %s", names)
+	}
+	mustSucceed(t, EmitEvents(ctx))
+	if len(ctx.events) != 0 {
+		t.Fatal("events left queued after EmitEvents")
+	}
+	event := <-l.stub.ChaincodeEventsChannel
+	if event.EventName != BatchEvent {
+		t.Fatalf("set %s", event.EventName)
+	}
+	var batch []ChaincodeEvent
+	mustSucceed(t, json.Unmarshal(event.Payload, &batch))
+	placed := &BidPlaced{}
+	mustSucceed(t, json.Unmarshal(batch[2].Payload, placed))
+	if placed.Bidder != "bob" || placed.Price != 300 || placed.PreviousBidder != "alice" || placed.PreviousPrice != 200 {
+		t.Fatalf("BidPlaced is %+v", placed)
+	}
+}
+
+func TestEmitEventsSetsSingleEventByName(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.seedNFT("1", "alice")
+
+	ctx := l.ctx("alice")
+	mustSucceed(t, s.TransferNFT(ctx, "bob", "1"))
+	mustSucceed(t, EmitEvents(ctx))
+	event := <-l.stub.ChaincodeEventsChannel
+	if event.EventName != NFTTransferredEvent {
+		t.Fatalf("set %s", event.EventName)
+	}
+	transferred := &NFTTransferred{}
+	mustSucceed(t, json.Unmarshal(event.Payload, transferred))
+	if transferred.TokenID != "1" || transferred.From != "alice" || transferred.To != "bob" {
+		t.Fatalf("NFTTransferred is %+v", transferred)
+	}
+
+	// a read-only transaction sets no event
+	ctx = l.ctx("reader")
+	_, err := s.GetNFTByID(ctx, "1")
+	mustSucceed(t, err)
+	mustSucceed(t, EmitEvents(ctx))
+	if len(l.stub.ChaincodeEventsChannel) != 0 {
+		t.Fatal("query set an event")
+	}
+}
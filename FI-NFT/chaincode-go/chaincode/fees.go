@@ -0,0 +1,52 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// SetFees changes the fee part of the configuration, see SetConfig. treasury must be an open account.
+func (s *SmartContract) SetFees(ctx contractapi.TransactionContextInterface, treasury string, mintFee uint64, saleCommissionBasisPoints uint64) (*Config, error) {
+	err := authorization(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to SetFees, not authenticated: %v\n", err)
+	}
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getConfig for SetFees: %v\n", err)
+	}
+	config.Treasury = treasury
+	config.MintFee = mintFee
+	config.SaleCommissionBasisPoints = saleCommissionBasisPoints
+	err = putConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to SetFees: %v\n", err)
+	}
+	return config, nil
+}
+
+// GetTreasury returns the balance of the treasury account, the fees collected so far.
+func (s *SmartContract) GetTreasury(ctx contractapi.TransactionContextInterface) (*AccountBalance, error) {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getConfig for GetTreasury: %v\n", err)
+	}
+	if config.Treasury == "" {
+		return nil, fmt.Errorf("failed to GetTreasury, treasury account is not set\n")
+	}
+	return getAccountBalance(ctx, config.Treasury)
+}
+
+// creditTreasury adds amount to the treasury account. Fees cannot be collected before the admin
+// sets the treasury with InitLedger or SetFees.
+func creditTreasury(ctx contractapi.TransactionContextInterface, config *Config, amount uint64) error {
+	if amount == 0 {
+		return nil
+	}
+	if config.Treasury == "" {
+		return fmt.Errorf("treasury account is not set\n")
+	}
+	_, err := credit(ctx, config.Treasury, amount)
+	return err
+}
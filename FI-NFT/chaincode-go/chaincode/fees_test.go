@@ -0,0 +1,84 @@
+package chaincode
+
+import "testing"
+
+// openTreasury opens the treasury account and sets it with the default fees.
+func (l *testLedger) openTreasury() {
+	l.t.Helper()
+	l.openAccounts(0, "treasury")
+	_, err := new(SmartContract).SetFees(l.adminCtx(), "treasury", MINT_FEE, 0)
+	mustSucceed(l.t, err)
+}
+
+func TestSetFeesNeedsAdminAndRoomForRoyalties(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	_, err := s.SetFees(l.ctx("alice"), "fees", 5, 250)
+	mustFail(t, err, "not authenticated")
+	_, err = s.SetFees(l.adminCtx(), "", 5, 250)
+	mustFail(t, err, "empty treasury account")
+	_, err = s.SetFees(l.adminCtx(), "fees", 5, 250)
+	mustFail(t, err, "treasury account fees is not open")
+	_, err = s.GetTreasury(l.ctx("reader"))
+	mustFail(t, err, "treasury account is not set")
+	l.openAccounts(0, "fees")
+	_, err = s.SetFees(l.adminCtx(), "fees", 5, 10000-MAX_ROYALTY_BASIS_POINTS+1)
+	mustFail(t, err, "exceed 10000 basis points")
+	config, err := s.SetFees(l.adminCtx(), "fees", 5, 250)
+	mustSucceed(t, err)
+	if config.Treasury != "fees" || config.MintFee != 5 || config.SaleCommissionBasisPoints != 250 {
+		t.Fatalf("config is %+v", config)
+	}
+	treasury, err := s.GetTreasury(l.ctx("reader"))
+	mustSucceed(t, err)
+	if treasury.Account != "fees" || treasury.Balance != 0 {
+		t.Fatalf("treasury before any fee is %+v", treasury)
+	}
+}
+
+func TestFeesGoToTreasury(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "creator", "alice")
+	l.openAccounts(0, "fees")
+	_, err := s.SetFees(l.adminCtx(), "fees", 5, 250)
+	mustSucceed(t, err)
+	supply, err := s.TotalSupply(l.ctx("reader"))
+	mustSucceed(t, err)
+
+	_, err = s.MintWithCID(l.ctx("creator"), "1", testCID, "png", 100, 0)
+	mustSucceed(t, err)
+	l.expectBalance("creator", 995)
+	l.expectBalance("fees", 5)
+
+	settled := l.sell("1", "creator", "alice", 400)
+	if settled.Commission != 10 {
+		t.Fatalf("commission is %d", settled.Commission)
+	}
+	l.expectBalance("creator", 995+390)
+	l.expectBalance("alice", 600)
+	l.expectBalance("fees", 15)
+	treasury, err := s.GetTreasury(l.ctx("reader"))
+	mustSucceed(t, err)
+	if treasury.Balance != 15 {
+		t.Fatalf("treasury is %+v", treasury)
+	}
+	after, err := s.TotalSupply(l.ctx("reader"))
+	mustSucceed(t, err)
+	if after != supply {
+		t.Fatalf("fees changed the total supply from %d to %d", supply, after)
+	}
+}
+
+func TestMintFailsWithoutTheMintFee(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(MINT_FEE-1, "creator")
+	_, err := s.MintWithCID(l.ctx("creator"), "1", testCID, "png", 100, 0)
+	mustFail(t, err, "no enough balance")
+	l.openAccounts(MINT_FEE, "minter")
+	_, err = s.MintWithCID(l.ctx("minter"), "2", testCID, "png", 100, 0)
+	mustFail(t, err, "treasury account is not set")
+	_, err = s.GetNFTByID(l.ctx("reader"), "1")
+	mustFail(t, err, "NFT not exist")
+}
@@ -0,0 +1,112 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const BidHistoryPrefix = "auction~tokenID~seq"
+const BidSeqPrefix = "tokenID~bidSeq"
+
+// BidRecord is one accepted bid on tokenID, kept after the auction settles or is cancelled.
+// Seq numbers every bid ever placed on the token, AuctionCreateTime tells its auctions apart.
+type BidRecord struct {
+	TokenID           string
+	Seq               uint64
+	AuctionType       string
+	AuctionCreateTime uint64
+	Bidder            string
+	Amount            uint64
+	Timestamp         uint64
+	TxID              string
+}
+
+type BidHistoryPage struct {
+	Records             []*BidRecord
+	FetchedRecordsCount int32
+	Bookmark            string
+}
+
+// GetBidHistory returns one page of the bids placed on tokenID, oldest first, across all of its auctions.
+// Sealed bids appear once revealed.
+func (s *SmartContract) GetBidHistory(ctx contractapi.TransactionContextInterface, tokenID string, pageSize int32, bookmark string) (*BidHistoryPage, error) {
+	err := checkPageSize(pageSize)
+	if err != nil {
+		return nil, err
+	}
+	iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(BidHistoryPrefix, []string{tokenID}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetStateByPartialCompositeKeyWithPagination for GetBidHistory: %v\n", err)
+	}
+	defer iter.Close()
+
+	page := &BidHistoryPage{Records: []*BidRecord{}, FetchedRecordsCount: meta.FetchedRecordsCount, Bookmark: meta.Bookmark}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate for GetBidHistory: %v\n", err)
+		}
+		record := &BidRecord{}
+		err = json.Unmarshal(kv.Value, record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data %v", err)
+		}
+		page.Records = append(page.Records, record)
+	}
+	return page, nil
+}
+
+// recordBid appends a bid of amount by bidder on the auction bid to the history of its token.
+func recordBid(ctx contractapi.TransactionContextInterface, bid *NFTBid, bidder string, amount uint64) error {
+	seqKey, err := ctx.GetStub().CreateCompositeKey(BidSeqPrefix, []string{bid.TokenID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := ctx.GetStub().GetState(seqKey)
+	if err != nil {
+		return fmt.Errorf("failed to getstate for key: %s, %v", seqKey, err)
+	}
+	var seq uint64
+	if len(jvalue) != 0 {
+		err = json.Unmarshal(jvalue, &seq)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal data %v", err)
+		}
+	}
+	seq++
+
+	timestamp, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	record := &BidRecord{
+		TokenID:           bid.TokenID,
+		Seq:               seq,
+		AuctionType:       bid.AuctionType,
+		AuctionCreateTime: bid.CreateTime,
+		Bidder:            bidder,
+		Amount:            amount,
+		Timestamp:         timestamp,
+		TxID:              ctx.GetStub().GetTxID(),
+	}
+	// zero padded so that the keys, and GetBidHistory, are in bid order
+	key, err := ctx.GetStub().CreateCompositeKey(BidHistoryPrefix, []string{bid.TokenID, fmt.Sprintf("%020d", seq)})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err = json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data %v", err)
+	}
+	err = ctx.GetStub().PutState(key, jvalue)
+	if err != nil {
+		return err
+	}
+	jvalue, err = json.Marshal(seq)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data %v", err)
+	}
+	return ctx.GetStub().PutState(seqKey, jvalue)
+}
@@ -0,0 +1,107 @@
+package chaincode
+
+import "testing"
+
+func TestBidHistoryKeepsEveryOffer(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice", "bob")
+	l.seedNFT("1", "seller")
+	first, err := s.AddBid(l.ctx("seller"), "1", 100, 900, 10, 0, 0)
+	mustSucceed(t, err)
+	mustSucceed(t, s.Offer(l.ctx("alice"), 150, "1"))
+	l.advance(1)
+	mustSucceed(t, s.Offer(l.ctx("bob"), 200, "1"))
+	_, err = s.UpdateBid(l.ctx("alice"), "1", 250)
+	mustSucceed(t, err)
+	mustFail(t, s.Offer(l.ctx("bob"), 240, "1"), "lower than minimum next bid")
+	l.advance(10)
+	mustSucceed(t, s.TryEndBid(l.ctx("reader"), "1"))
+
+	// a second auction of the same token, now sold by alice
+	second, err := s.AddBid(l.ctx("alice"), "1", 100, 900, 10, 0, 0)
+	mustSucceed(t, err)
+	mustSucceed(t, s.Offer(l.ctx("bob"), 300, "1"))
+
+	page, err := s.GetBidHistory(l.ctx("reader"), "1", 10, "")
+	mustSucceed(t, err)
+	want := []struct {
+		bidder  string
+		amount  uint64
+		created uint64
+	}{
+		{"alice", 150, first.CreateTime},
+		{"bob", 200, first.CreateTime},
+		{"alice", 250, first.CreateTime},
+		{"bob", 300, second.CreateTime},
+	}
+	if len(page.Records) != len(want) {
+		t.Fatalf("history has %d records, want %d", len(page.Records), len(want))
+	}
+	for i, w := range want {
+		record := page.Records[i]
+		if record.Seq != uint64(i+1) || record.Bidder != w.bidder || record.Amount != w.amount || record.AuctionCreateTime != w.created {
+			t.Fatalf("record %d is %+v", i, record)
+		}
+		if record.AuctionType != AuctionEnglish || record.TxID == "" {
+			t.Fatalf("record %d is %+v", i, record)
+		}
+	}
+	if page.Records[0].Timestamp != first.CreateTime || page.Records[1].Timestamp != first.CreateTime+60*1000 {
+		t.Fatalf("timestamps are %d and %d", page.Records[0].Timestamp, page.Records[1].Timestamp)
+	}
+}
+
+func TestBidHistoryPages(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice", "bob")
+	l.seedNFT("1", "seller")
+	l.seedNFT("2", "seller")
+	for _, tokenID := range []string{"1", "2"} {
+		_, err := s.AddBid(l.ctx("seller"), tokenID, 100, 900, 10, 0, 0)
+		mustSucceed(t, err)
+	}
+	for i, bidder := range []string{"alice", "bob", "alice"} {
+		mustSucceed(t, s.Offer(l.ctx(bidder), uint64(100+i*10), "1"))
+	}
+	mustSucceed(t, s.Offer(l.ctx("bob"), 500, "2"))
+
+	page, err := s.GetBidHistory(l.ctx("reader"), "1", 2, "")
+	mustSucceed(t, err)
+	if len(page.Records) != 2 || page.Records[0].Amount != 100 || page.Records[1].Amount != 110 || page.Bookmark == "" {
+		t.Fatalf("first page is %+v", page)
+	}
+	page, err = s.GetBidHistory(l.ctx("reader"), "1", 2, page.Bookmark)
+	mustSucceed(t, err)
+	if len(page.Records) != 1 || page.Records[0].Amount != 120 || page.Records[0].Seq != 3 {
+		t.Fatalf("second page is %+v", page)
+	}
+	_, err = s.GetBidHistory(l.ctx("reader"), "1", 0, "")
+	mustFail(t, err, "page size must be in")
+}
+
+func TestBidHistoryRecordsRevealedSealedBids(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", AuctionSealedBid, 100, 0, 10)
+	mustSucceed(t, err)
+	l.setSealedBid(300, "pepper")
+	mustSucceed(t, s.CommitBid(l.ctx("alice"), "1", SealedBidHash("1", "alice", 300, "pepper"), 400))
+
+	page, err := s.GetBidHistory(l.ctx("reader"), "1", 10, "")
+	mustSucceed(t, err)
+	if len(page.Records) != 0 {
+		t.Fatalf("committed bid is in the history: %+v", page.Records[0])
+	}
+	l.advance(11)
+	_, err = s.RevealBid(l.ctx("alice"), "1")
+	mustSucceed(t, err)
+	page, err = s.GetBidHistory(l.ctx("reader"), "1", 10, "")
+	mustSucceed(t, err)
+	if len(page.Records) != 1 || page.Records[0].Bidder != "alice" || page.Records[0].Amount != 300 || page.Records[0].AuctionType != AuctionSealedBid {
+		t.Fatalf("history is %+v", page.Records)
+	}
+}
@@ -0,0 +1,119 @@
+package chaincode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Limits on the metadata accepted by MintWithMetadata, lengths are in bytes.
+const MAX_NAME_LENGTH = 128
+const MAX_DESCRIPTION_LENGTH = 4096
+const MAX_ATTRIBUTES = 64
+const MAX_ATTRIBUTE_LENGTH = 256
+
+// NFTMetadata is the ERC-721 metadata JSON document of a token, the one stored on IPFS at its MetadataCID.
+// Image, when set, must be the ipfs:// URI of the token content. Properties outside the schema are not accepted.
+type NFTMetadata struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description" metadata:",optional"`
+	Image       string         `json:"image" metadata:",optional"`
+	Attributes  []NFTAttribute `json:"attributes" metadata:",optional"`
+}
+
+// NFTAttribute is one trait of a token. Value is a string, numeric traits are given in decimal,
+// with DisplayType telling the UI how to show them.
+type NFTAttribute struct {
+	TraitType   string `json:"trait_type"`
+	Value       string `json:"value"`
+	DisplayType string `json:"display_type,omitempty" metadata:",optional"`
+}
+
+// MintWithMetadata is MintWithCID, or MintEditions for a non-zero supply, for a token described by
+// metadata. metadataCID is the IPFS document holding metadata as JSON, which the chaincode cannot fetch:
+// that it matches is checked off-chain like the content, see AttestContent.
+func (s *SmartContract) MintWithMetadata(ctx contractapi.TransactionContextInterface, tokenID string, cid string, ftype string, size uint64, royaltyBasisPoints uint64, supply uint64, metadataCID string, metadata *NFTMetadata) (*NFT, error) {
+	value := &NFT{
+		ID:                 tokenID,
+		CID:                cid,
+		FileType:           ftype,
+		Size:               size,
+		RoyaltyBasisPoints: royaltyBasisPoints,
+		Supply:             supply,
+	}
+	err := setCID(value, cid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to MintWithMetadata, %v\n", err)
+	}
+	err = validateMetadata(metadata, value.CID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to MintWithMetadata, %v\n", err)
+	}
+	parsed, err := parseCID(metadataCID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to MintWithMetadata, metadata %v\n", err)
+	}
+	value.MetadataCID = parsed.CID
+	value.Name = metadata.Name
+	value.Description = metadata.Description
+	value.Attributes = metadata.Attributes
+
+	err = mintCID(ctx, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to MintWithMetadata: %v\n", err)
+	}
+	return value, nil
+}
+
+// TokenURI returns the ipfs:// URI of the metadata JSON of tokenID, the ERC-721 tokenURI.
+// Tokens minted without metadata have none.
+func (s *SmartContract) TokenURI(ctx contractapi.TransactionContextInterface, tokenID string) (string, error) {
+	nft, err := getNFT(ctx, tokenID)
+	if err != nil {
+		return "", fmt.Errorf("failed to getNFT for TokenURI: %v\n", err)
+	}
+	if nft.MetadataCID == "" {
+		return "", fmt.Errorf("token %s has no metadata\n", tokenID)
+	}
+	return "ipfs://" + nft.MetadataCID, nil
+}
+
+// validateMetadata checks metadata against the limits above, for a token whose content is cid.
+func validateMetadata(metadata *NFTMetadata, cid string) error {
+	if metadata == nil || metadata.Name == "" {
+		return fmt.Errorf("metadata has no name\n")
+	}
+	if len(metadata.Name) > MAX_NAME_LENGTH || len(metadata.Description) > MAX_DESCRIPTION_LENGTH {
+		return fmt.Errorf("metadata name or description too long, max %d and %d bytes\n", MAX_NAME_LENGTH, MAX_DESCRIPTION_LENGTH)
+	}
+	if metadata.Image != "" {
+		if !strings.HasPrefix(metadata.Image, "ipfs://") {
+			return fmt.Errorf("metadata image %q is not an ipfs:// URI\n", metadata.Image)
+		}
+		image, err := parseCID(strings.TrimPrefix(metadata.Image, "ipfs://"))
+		if err != nil {
+			return fmt.Errorf("metadata image %v", err)
+		}
+		if image.CID != cid {
+			return fmt.Errorf("metadata image %s is not the token content %s\n", image.CID, cid)
+		}
+	}
+	if len(metadata.Attributes) > MAX_ATTRIBUTES {
+		return fmt.Errorf("%d attributes, max %d\n", len(metadata.Attributes), MAX_ATTRIBUTES)
+	}
+	traits := map[string]bool{}
+	for _, attribute := range metadata.Attributes {
+		if attribute.TraitType == "" || attribute.Value == "" {
+			return fmt.Errorf("attribute without trait_type or value\n")
+		}
+		if len(attribute.TraitType) > MAX_ATTRIBUTE_LENGTH || len(attribute.Value) > MAX_ATTRIBUTE_LENGTH || len(attribute.DisplayType) > MAX_ATTRIBUTE_LENGTH {
+			return fmt.Errorf("attribute %s too long, max %d bytes\n", attribute.TraitType, MAX_ATTRIBUTE_LENGTH)
+		}
+		if traits[attribute.TraitType] {
+			return fmt.Errorf("attribute %s appears twice\n", attribute.TraitType)
+		}
+		traits[attribute.TraitType] = true
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// contractLedger invokes the chaincode as the peer does, through contractapi, which checks the arguments
+// and return values of every transaction against the contract metadata.
+type contractLedger struct {
+	t    *testing.T
+	stub *shimtest.MockStub
+	txs  int
+}
+
+// newContractLedger starts the chaincode for a single client of mspID.
+func newContractLedger(t *testing.T, mspID string) *contractLedger {
+	contract := new(SmartContract)
+	contract.TransactionContextHandler = new(TransactionContext)
+	contract.AfterTransaction = EmitEvents
+	cc, err := contractapi.NewChaincode(contract)
+	mustSucceed(t, err)
+	stub := shimtest.NewMockStub("fi-nft", cc)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	mustSucceed(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	mustSucceed(t, err)
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	mustSucceed(t, err)
+	stub.Creator = creator
+	return &contractLedger{t: t, stub: stub}
+}
+
+// invoke runs fn with args, given as JSON for structs, and returns its payload or error message.
+func (c *contractLedger) invoke(fn string, args ...string) (string, error) {
+	c.txs++
+	call := [][]byte{[]byte(fn)}
+	for _, arg := range args {
+		call = append(call, []byte(arg))
+	}
+	response := c.stub.MockInvoke(fmt.Sprintf("tx%d", c.txs), call)
+	if response.Status != 200 {
+		return "", fmt.Errorf("%s", response.Message)
+	}
+	return string(response.Payload), nil
+}
+
+func TestTokensWithoutMetadataPassTheContractSchema(t *testing.T) {
+	c := newContractLedger(t, AdmintMSPID)
+	account, err := c.invoke("ClientAccountID")
+	mustSucceed(t, err)
+	_, err = c.invoke("InitAccountBalance", account, "100")
+	mustSucceed(t, err)
+	_, err = c.invoke("InitLedger", account)
+	mustSucceed(t, err)
+
+	_, err = c.invoke("MintWithCID", "1", testCID, "png", "10", "0")
+	mustSucceed(t, err)
+	payload, err := c.invoke("GetNFTByID", "1")
+	mustSucceed(t, err)
+	nft := &NFT{}
+	mustSucceed(t, json.Unmarshal([]byte(payload), nft))
+	if nft.ID != "1" || nft.CID != testNormalizedCID || nft.Owner != account || nft.Attributes != nil {
+		t.Fatalf("read back %+v", nft)
+	}
+	if strings.Contains(payload, "Attributes") || strings.Contains(payload, "MetadataCID") {
+		t.Fatalf("empty metadata is in %s", payload)
+	}
+	_, err = c.invoke("GetNFTByCID", testCID)
+	mustSucceed(t, err)
+
+	// a metadata document with just a name
+	metadataCID := "QmcRD4wkPPi6dig81r5sLj9Zm1gDCL4zgpEj9CfuRrGbzF"
+	other := "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"
+	_, err = c.invoke("MintWithMetadata", "2", other, "png", "10", "0", "0", metadataCID, `{"name":"n"}`)
+	mustSucceed(t, err)
+	payload, err = c.invoke("GetNFTByID", "2")
+	mustSucceed(t, err)
+	nft = &NFT{}
+	mustSucceed(t, json.Unmarshal([]byte(payload), nft))
+	if nft.Name != "n" || nft.Description != "" || nft.MetadataCID == "" {
+		t.Fatalf("read back %+v", nft)
+	}
+	// properties outside the metadata schema are rejected
+	_, err = c.invoke("MintWithMetadata", "3", testCID, "png", "10", "0", "0", metadataCID, `{"name":"n","color":"red"}`)
+	mustFail(t, err, "Additional property color is not allowed")
+}
+
+func TestMintWithMetadataValidatesTheDocument(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openTreasury()
+	l.openAccounts(100, "minter")
+	metadataCID := "QmcRD4wkPPi6dig81r5sLj9Zm1gDCL4zgpEj9CfuRrGbzF"
+	for _, invalid := range []struct {
+		metadata *NFTMetadata
+		want     string
+	}{
+		{&NFTMetadata{}, "metadata has no name"},
+		{&NFTMetadata{Name: strings.Repeat("n", MAX_NAME_LENGTH+1)}, "name or description too long"},
+		{&NFTMetadata{Name: "n", Image: "https://example.com/1.png"}, "is not an ipfs:// URI"},
+		{&NFTMetadata{Name: "n", Image: "ipfs://" + metadataCID}, "is not the token content"},
+		{&NFTMetadata{Name: "n", Attributes: []NFTAttribute{{TraitType: "color"}}}, "attribute without trait_type or value"},
+		{&NFTMetadata{Name: "n", Attributes: []NFTAttribute{{TraitType: "color", Value: "red"}, {TraitType: "color", Value: "blue"}}}, "attribute color appears twice"},
+	} {
+		_, err := s.MintWithMetadata(l.ctx("minter"), "1", testCID, "png", 10, 0, 0, metadataCID, invalid.metadata)
+		mustFail(t, err, invalid.want)
+	}
+	_, err := s.MintWithMetadata(l.ctx("minter"), "1", testCID, "png", 10, 0, 0, "hello", &NFTMetadata{Name: "n"})
+	mustFail(t, err, "metadata malformed CID")
+	_, err = s.TokenURI(l.ctx("reader"), "1")
+	mustFail(t, err, "NFT not exist")
+
+	metadata := &NFTMetadata{
+		Name:        "n",
+		Description: "d",
+		Image:       "ipfs://" + testCID,
+		Attributes:  []NFTAttribute{{TraitType: "level", Value: "3", DisplayType: "number"}},
+	}
+	nft, err := s.MintWithMetadata(l.ctx("minter"), "1", testCID, "png", 10, 0, 0, metadataCID, metadata)
+	mustSucceed(t, err)
+	if nft.Name != "n" || nft.Description != "d" || len(nft.Attributes) != 1 || nft.Attributes[0] != metadata.Attributes[0] || nft.Creator != "minter" {
+		t.Fatalf("minted %+v", nft)
+	}
+	uri, err := s.TokenURI(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	parsed, err := parseCID(metadataCID)
+	mustSucceed(t, err)
+	if uri != "ipfs://"+parsed.CID {
+		t.Fatalf("token URI is %s", uri)
+	}
+
+	_, err = s.MintWithCID(l.ctx("minter"), "2", "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi", "png", 10, 0)
+	mustSucceed(t, err)
+	_, err = s.TokenURI(l.ctx("reader"), "2")
+	mustFail(t, err, "token 2 has no metadata")
+}
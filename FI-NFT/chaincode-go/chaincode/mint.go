@@ -0,0 +1,158 @@
+package chaincode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// MintWithCID mints tokenID for content the client already added to IPFS. Unlike MintWithFile the
+// chaincode never calls IPFS: cid is only parsed and normalized, see parseCID, and size is the content
+// length in bytes declared by the client. Whether the content can be fetched is attested afterwards
+// with AttestContent.
+func (s *SmartContract) MintWithCID(ctx contractapi.TransactionContextInterface, tokenID string, cid string, ftype string, size uint64, royaltyBasisPoints uint64) (*NFT, error) {
+	value := &NFT{
+		ID:                 tokenID,
+		CID:                cid,
+		FileType:           ftype,
+		Size:               size,
+		RoyaltyBasisPoints: royaltyBasisPoints,
+	}
+	err := mintCID(ctx, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to MintWithCID: %v\n", err)
+	}
+	return value, nil
+}
+
+// AttestContent records that the caller, an off-chain verifier running with an admin identity, fetched
+// the content of tokenID from IPFS and found it matching its CID. Attesting again replaces the attestation.
+func (s *SmartContract) AttestContent(ctx contractapi.TransactionContextInterface, tokenID string) (*NFT, error) {
+	err := authorization(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to AttestContent, not authenticated: %v\n", err)
+	}
+	verifier, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client id: %v", err)
+	}
+	nft, err := getNFT(ctx, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getNFT for AttestContent: %v\n", err)
+	}
+	verifyTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getTxTime for AttestContent: %v\n", err)
+	}
+	nft.Verifier = verifier
+	nft.VerifyTime = verifyTime
+	err = putNFT(ctx, nft)
+	if err != nil {
+		return nil, fmt.Errorf("failed to PutState for AttestContent: %v\n", err)
+	}
+	err = emitEvent(ctx, ContentAttestedEvent, &ContentAttested{TokenID: tokenID, CID: nft.CID, Verifier: verifier, VerifyTime: verifyTime})
+	if err != nil {
+		return nil, err
+	}
+	return nft, nil
+}
+
+// mintCID mints value, whose CID is supplied by the caller, for the caller.
+func mintCID(ctx contractapi.TransactionContextInterface, value *NFT) error {
+	operator, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+	config, err := getConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to getConfig: %v\n", err)
+	}
+	err = checkMint(ctx, config, operator, value.RoyaltyBasisPoints)
+	if err != nil {
+		return err
+	}
+	err = setCID(value, value.CID)
+	if err != nil {
+		return err
+	}
+	return storeMint(ctx, config, operator, value)
+}
+
+// checkMint fails if operator cannot pay the mint fee or royaltyBasisPoints is above the configured cap.
+func checkMint(ctx contractapi.TransactionContextInterface, config *Config, operator string, royaltyBasisPoints uint64) error {
+	if royaltyBasisPoints > config.MaxRoyaltyBasisPoints {
+		return fmt.Errorf("royalty %d exceeds %d basis points\n", royaltyBasisPoints, config.MaxRoyaltyBasisPoints)
+	}
+	balance, err := getAccountBalance(ctx, operator)
+	if err != nil {
+		return err
+	}
+	if balance.Balance < config.MintFee {
+		return fmt.Errorf("no enough balance. has: %d, need at least: %d\n", balance.Balance, config.MintFee)
+	}
+	return nil
+}
+
+// storeMint records nft as created by operator, who owns it or, for a token class, holds all of
+// its editions, and charges operator the mint fee. The tokenID and the content must both be new.
+func storeMint(ctx contractapi.TransactionContextInterface, config *Config, operator string, nft *NFT) error {
+	if strings.Contains(nft.ID, LOT_SEPARATOR) {
+		return fmt.Errorf("tokenID %s contains %s, which is reserved for lot IDs\n", nft.ID, LOT_SEPARATOR)
+	}
+	exists, err := nftExists(ctx, nft.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("token %s already exists\n", nft.ID)
+	}
+	err = checkContentUnique(ctx, nft.CID)
+	if err != nil {
+		return err
+	}
+	nft.MintTime, err = getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	nft.Creator = operator
+	nft.Owner = operator
+	if nft.Supply > 0 {
+		//the editions are held under EditionPrefix
+		nft.Owner = ""
+	}
+	err = putNFT(ctx, nft)
+	if err != nil {
+		return fmt.Errorf("failed to PutState for mint %v\n", err)
+	}
+	err = addCIDToIndex(ctx, nft.CID, nft.ID)
+	if err != nil {
+		return fmt.Errorf("failed to index CID: %v\n", err)
+	}
+	_, err = debit(ctx, operator, config.MintFee)
+	if err != nil {
+		return fmt.Errorf("failed to take out mint fee: %v\n", err)
+	}
+	err = creditTreasury(ctx, config, config.MintFee)
+	if err != nil {
+		return fmt.Errorf("failed to credit mint fee: %v\n", err)
+	}
+	if nft.Supply > 0 {
+		err = creditEditions(ctx, nft.ID, operator, nft.Supply)
+	} else {
+		err = addNFTToList(ctx, operator, nft.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to addNFTToList: %v", err)
+	}
+	return emitEvent(ctx, NFTMintedEvent, &NFTMinted{
+		TokenID:            nft.ID,
+		CID:                nft.CID,
+		Owner:              operator,
+		FileType:           nft.FileType,
+		Size:               nft.Size,
+		RoyaltyBasisPoints: nft.RoyaltyBasisPoints,
+		Supply:             nft.Supply,
+		MetadataCID:        nft.MetadataCID,
+	})
+}
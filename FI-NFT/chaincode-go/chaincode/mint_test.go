@@ -0,0 +1,77 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// testNormalizedCID is testCID as stored, in CIDv1 base32.
+const testNormalizedCID = "bafybeie5nqv6kd3qnfjupgvz34woh3oksc3iau6abmyajn7qvtf6d2ho34"
+
+func TestMintWithCIDRecordsTheClientCID(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openTreasury()
+	l.openAccounts(100, "minter")
+	_, err := s.MintWithCID(l.ctx("minter"), "1", "QmBAD", "png", 10, 0)
+	mustFail(t, err, "malformed CID \"QmBAD\"")
+	l.expectBalance("minter", 100)
+
+	ctx := l.ctx("minter")
+	nft, err := s.MintWithCID(ctx, "1", testCID, "png", 10, 100)
+	mustSucceed(t, err)
+	if nft.CID != testNormalizedCID || nft.Owner != "minter" || nft.Creator != "minter" || nft.Size != 10 || nft.FileType != "png" {
+		t.Fatalf("minted %+v", nft)
+	}
+	if nft.MintTime != l.nowMillis() || nft.Verifier != "" || nft.VerifyTime != 0 {
+		t.Fatalf("minted %+v", nft)
+	}
+	minted := &NFTMinted{}
+	for _, event := range ctx.events {
+		if event.Name == NFTMintedEvent {
+			mustSucceed(t, json.Unmarshal(event.Payload, minted))
+		}
+	}
+	if minted.TokenID != "1" || minted.CID != testNormalizedCID || minted.Owner != "minter" {
+		t.Fatalf("NFTMinted is %+v", minted)
+	}
+	l.expectBalance("minter", 100-MINT_FEE)
+	if owner := l.owner("1"); owner != "minter" {
+		t.Fatalf("owner is %s", owner)
+	}
+
+	_, err = s.MintWithCID(l.ctx("minter"), "1", "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi", "png", 10, 0)
+	mustFail(t, err, "token 1 already exists")
+}
+
+func TestAttestContentNeedsAdmin(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openTreasury()
+	l.openAccounts(100, "minter")
+	_, err := s.MintWithCID(l.ctx("minter"), "1", testCID, "png", 10, 0)
+	mustSucceed(t, err)
+
+	_, err = s.AttestContent(l.ctx("minter"), "1")
+	mustFail(t, err, "not authenticated")
+	_, err = s.AttestContent(l.ctxOf("verifier", AdmintMSPID), "2")
+	mustFail(t, err, "NFT not exist")
+
+	l.advance(5)
+	ctx := l.ctxOf("verifier", AdmintMSPID)
+	nft, err := s.AttestContent(ctx, "1")
+	mustSucceed(t, err)
+	if nft.Verifier != "verifier" || nft.VerifyTime != l.nowMillis() || nft.Owner != "minter" {
+		t.Fatalf("attested %+v", nft)
+	}
+	attested := &ContentAttested{}
+	mustSucceed(t, json.Unmarshal(ctx.events[len(ctx.events)-1].Payload, attested))
+	if attested.TokenID != "1" || attested.CID != testNormalizedCID || attested.Verifier != "verifier" {
+		t.Fatalf("ContentAttested is %+v", attested)
+	}
+	stored, err := s.GetNFTByID(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if stored.Verifier != "verifier" || stored.VerifyTime != nft.VerifyTime {
+		t.Fatalf("stored %+v", stored)
+	}
+}
@@ -0,0 +1,33 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RoyaltyInfoResult is the ERC-2981 answer: Receiver is owed RoyaltyAmount out of a sale.
+type RoyaltyInfoResult struct {
+	Receiver      string
+	RoyaltyAmount uint64
+}
+
+// RoyaltyInfo returns the royalty owed to the creator of tokenID if its owner sells it for salePrice,
+// or any holder of an edition of a token class.
+func (s *SmartContract) RoyaltyInfo(ctx contractapi.TransactionContextInterface, tokenID string, salePrice uint64) (*RoyaltyInfoResult, error) {
+	nft, err := getNFT(ctx, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getNFT for RoyaltyInfo: %v\n", err)
+	}
+	receiver, amount := royaltyOf(nft, nft.Owner, salePrice)
+	return &RoyaltyInfoResult{Receiver: receiver, RoyaltyAmount: amount}, nil
+}
+
+// royaltyOf splits the royalty off a sale of nft by seller. Nothing is owed when the creator
+// sells its own token or for tokens minted before royalties existed.
+func royaltyOf(nft *NFT, seller string, salePrice uint64) (string, uint64) {
+	if nft.Creator == "" || nft.Creator == seller {
+		return nft.Creator, 0
+	}
+	return nft.Creator, shareOf(salePrice, nft.RoyaltyBasisPoints)
+}
@@ -0,0 +1,85 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const testCID = "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG"
+
+// sell lists tokenID by seller at a fixed price and settles the sale to buyer.
+func (l *testLedger) sell(tokenID string, seller string, buyer string, price uint64) *AuctionSettled {
+	l.t.Helper()
+	s := new(SmartContract)
+	_, err := s.AddAuction(l.ctx(seller), tokenID, AuctionFixedPrice, 0, price, 10)
+	mustSucceed(l.t, err)
+	mustSucceed(l.t, s.Offer(l.ctx(buyer), price, tokenID))
+	ctx := l.ctx("reader")
+	mustSucceed(l.t, s.TryEndBid(ctx, tokenID))
+	settled := &AuctionSettled{}
+	mustSucceed(l.t, json.Unmarshal(ctx.events[len(ctx.events)-1].Payload, settled))
+	return settled
+}
+
+func TestRoyaltyPaidOnSecondarySales(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openTreasury()
+	l.openAccounts(1000, "creator", "alice", "bob")
+	_, err := s.MintWithCID(l.ctx("creator"), "1", testCID, "png", 100, MAX_ROYALTY_BASIS_POINTS+1)
+	mustFail(t, err, "exceeds 1000 basis points")
+	nft, err := s.MintWithCID(l.ctx("creator"), "1", testCID, "png", 100, 750)
+	mustSucceed(t, err)
+	if nft.Creator != "creator" || nft.RoyaltyBasisPoints != 750 {
+		t.Fatalf("minted %+v", nft)
+	}
+	l.expectBalance("creator", 1000-MINT_FEE)
+
+	info, err := s.RoyaltyInfo(l.ctx("reader"), "1", 1000)
+	mustSucceed(t, err)
+	if info.Receiver != "creator" || info.RoyaltyAmount != 0 {
+		t.Fatalf("royalty while the creator owns the token is %+v", info)
+	}
+
+	// the first sale is by the creator, so no royalty is owed
+	settled := l.sell("1", "creator", "alice", 200)
+	if settled.Royalty != 0 {
+		t.Fatalf("primary sale paid royalty %d", settled.Royalty)
+	}
+	l.expectBalance("creator", 1190)
+	info, err = s.RoyaltyInfo(l.ctx("reader"), "1", 1000)
+	mustSucceed(t, err)
+	if info.Receiver != "creator" || info.RoyaltyAmount != 75 {
+		t.Fatalf("royalty on a resale is %+v", info)
+	}
+
+	settled = l.sell("1", "alice", "bob", 400)
+	if settled.Royalty != 30 || settled.Seller != "alice" || settled.Winner != "bob" {
+		t.Fatalf("resale settled as %+v", settled)
+	}
+	l.expectBalance("creator", 1220)
+	l.expectBalance("alice", 800+370)
+	l.expectBalance("bob", 600)
+	nft, err = s.GetNFTByID(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if nft.Owner != "bob" || nft.Creator != "creator" || nft.RoyaltyBasisPoints != 750 {
+		t.Fatalf("token after resale is %+v", nft)
+	}
+}
+
+func TestNoRoyaltyForTokensWithoutCreator(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	info, err := s.RoyaltyInfo(l.ctx("reader"), "1", 1000)
+	mustSucceed(t, err)
+	if info.Receiver != "" || info.RoyaltyAmount != 0 {
+		t.Fatalf("royalty of a token minted before royalties is %+v", info)
+	}
+	settled := l.sell("1", "seller", "alice", 300)
+	if settled.Royalty != 0 {
+		t.Fatalf("sale paid royalty %d", settled.Royalty)
+	}
+	l.expectBalance("seller", 1300)
+}
@@ -0,0 +1,385 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const SealedBidPrefix = "tokenID~bidder~sealedBid"
+
+// Transient map keys carrying the hidden bid of CommitBid and RevealBid.
+const (
+	SealedAmountTransientKey = "amount"
+	SealedSaltTransientKey   = "salt"
+)
+
+// Phases of a sealed-bid auction reported by GetAuctionPhase, other auctions are PhaseOpen until they end.
+const (
+	PhaseOpen   = "open"
+	PhaseCommit = "commit"
+	PhaseReveal = "reveal"
+	PhaseEnded  = "ended"
+)
+
+// SealedBid is the commitment of one bidder to a sealed-bid auction. Deposit is held from the bidder's
+// balance at commit time and must cover the hidden Amount, which is only known once Revealed.
+type SealedBid struct {
+	TokenID    string
+	Bidder     string
+	Hash       string
+	Deposit    uint64
+	CommitTime uint64
+	Revealed   bool
+	Amount     uint64
+}
+
+// SealedBidHash is the commitment hash for a bid of amount on tokenID by bidder:
+// hex(sha256("<tokenID>:<bidder>:<amount>:<salt>")).
+func SealedBidHash(tokenID string, bidder string, amount uint64, salt string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%s:%d:%s", tokenID, bidder, amount, salt)))
+	return hex.EncodeToString(sum[:])
+}
+
+// CommitBid enters a hidden bid on a sealed-bid auction during its commit phase. The amount and salt are
+// passed in the transient map so they never reach the ledger, they must match hash and the amount must be
+// covered by deposit, which is held from the caller's balance until settlement. A bidder commits once.
+// The deposit is public, on the ledger and in BidCommitted, and is an upper bound of the bid: a bidder
+// that does not want to reveal how much it bids deposits more, the excess is refunded at settlement.
+func (s *SmartContract) CommitBid(ctx contractapi.TransactionContextInterface, tokenID string, hash string, deposit uint64) error {
+	bidder, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+	bid, err := getSealedAuction(ctx, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to CommitBid: %v\n", err)
+	}
+	currentTime, err := getTxTime(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to getTxTime for CommitBid: %v\n", err)
+	}
+	if sealedPhase(bid, currentTime) != PhaseCommit {
+		return fmt.Errorf("failed to CommitBid, commit phase is over\n")
+	}
+	nft, err := getNFT(ctx, auctionedToken(bid))
+	if err != nil {
+		return fmt.Errorf("failed to getNFT for CommitBid: %v\n", err)
+	}
+	if auctionSeller(nft, bid) == bidder {
+		return fmt.Errorf("failed to CommitBid, owner cannot bid\n")
+	}
+	existing, err := getSealedBid(ctx, tokenID, bidder)
+	if err != nil {
+		return fmt.Errorf("failed to getSealedBid for CommitBid: %v\n", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("failed to CommitBid, already committed\n")
+	}
+
+	amount, salt, err := getSealedBidTransient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to CommitBid: %v\n", err)
+	}
+	if SealedBidHash(tokenID, bidder, amount, salt) != hash {
+		return fmt.Errorf("failed to CommitBid, hash does not match amount and salt\n")
+	}
+	if amount < bid.LowerPrice {
+		return fmt.Errorf("failed to CommitBid, bid lower than minimum price %d\n", bid.LowerPrice)
+	}
+	if amount > deposit {
+		return fmt.Errorf("failed to CommitBid, deposit does not cover the bid\n")
+	}
+
+	_, err = debit(ctx, bidder, deposit)
+	if err != nil {
+		return fmt.Errorf("failed to take out deposit from bidder: %v\n", err)
+	}
+	err = putSealedBid(ctx, &SealedBid{
+		TokenID:    tokenID,
+		Bidder:     bidder,
+		Hash:       hash,
+		Deposit:    deposit,
+		CommitTime: currentTime,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to PutState for CommitBid: %v\n", err)
+	}
+	return emitEvent(ctx, BidCommittedEvent, &BidCommitted{TokenID: tokenID, Bidder: bidder, Deposit: deposit})
+}
+
+// RevealBid opens the caller's commitment during the reveal phase, with the same amount and salt
+// in the transient map as CommitBid. Commitments that are never revealed forfeit their deposit to the seller.
+func (s *SmartContract) RevealBid(ctx contractapi.TransactionContextInterface, tokenID string) (*SealedBid, error) {
+	bidder, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client id: %v", err)
+	}
+	bid, err := getSealedAuction(ctx, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to RevealBid: %v\n", err)
+	}
+	currentTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getTxTime for RevealBid: %v\n", err)
+	}
+	if sealedPhase(bid, currentTime) != PhaseReveal {
+		return nil, fmt.Errorf("failed to RevealBid, not in reveal phase\n")
+	}
+	sealed, err := getSealedBid(ctx, tokenID, bidder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getSealedBid for RevealBid: %v\n", err)
+	}
+	if sealed == nil {
+		return nil, fmt.Errorf("failed to RevealBid, no commitment\n")
+	}
+	if sealed.Revealed {
+		return nil, fmt.Errorf("failed to RevealBid, already revealed\n")
+	}
+	amount, salt, err := getSealedBidTransient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to RevealBid: %v\n", err)
+	}
+	if SealedBidHash(tokenID, bidder, amount, salt) != sealed.Hash {
+		return nil, fmt.Errorf("failed to RevealBid, amount and salt do not match commitment\n")
+	}
+
+	sealed.Revealed = true
+	sealed.Amount = amount
+	err = putSealedBid(ctx, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to PutState for RevealBid: %v\n", err)
+	}
+	err = recordBid(ctx, bid, bidder, amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record bid for RevealBid: %v\n", err)
+	}
+	err = emitEvent(ctx, BidRevealedEvent, &BidRevealed{TokenID: tokenID, Bidder: bidder, Amount: amount})
+	if err != nil {
+		return nil, err
+	}
+	return sealed, nil
+}
+
+// GetAuctionPhase returns the phase of the auction of tokenID at the transaction time.
+func (s *SmartContract) GetAuctionPhase(ctx contractapi.TransactionContextInterface, tokenID string) (string, error) {
+	bid, err := getBid(ctx, tokenID)
+	if err != nil {
+		return "", fmt.Errorf("failed to getBid for GetAuctionPhase: %v\n", err)
+	}
+	currentTime, err := getTxTime(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to getTxTime for GetAuctionPhase: %v\n", err)
+	}
+	if bid.AuctionType == AuctionSealedBid {
+		return sealedPhase(bid, currentTime), nil
+	}
+	format, err := getAuctionFormat(bid)
+	if err != nil {
+		return "", fmt.Errorf("failed to GetAuctionPhase: %v\n", err)
+	}
+	if format.ended(bid, currentTime) {
+		return PhaseEnded, nil
+	}
+	return PhaseOpen, nil
+}
+
+type sealedBidAuction struct{}
+
+func (sealedBidAuction) open(bid *NFTBid, lowerPrice uint64, upPrice uint64, config *Config) error {
+	bid.CurrentPrice = lowerPrice
+	bid.LowerPrice = lowerPrice
+	bid.KillPrice = 0
+	bid.CommitDeadline = bid.CreateTime + bid.LifeTime
+	bid.RevealDeadline = bid.CommitDeadline + config.SealedRevealLifeTime*60*1000
+	return nil
+}
+
+func (sealedBidAuction) offer(ctx contractapi.TransactionContextInterface, bid *NFTBid, bidder string, price uint64, currentTime uint64) error {
+	return fmt.Errorf("sealed-bid auction takes bids through CommitBid and RevealBid\n")
+}
+
+func (sealedBidAuction) ended(bid *NFTBid, currentTime uint64) bool {
+	return sealedPhase(bid, currentTime) == PhaseEnded
+}
+
+func (sealedBidAuction) hasBids(ctx contractapi.TransactionContextInterface, bid *NFTBid) (bool, error) {
+	sealedBids, err := getSealedBids(ctx, bid.TokenID)
+	if err != nil {
+		return false, err
+	}
+	return len(sealedBids) != 0, nil
+}
+
+// settle picks the highest revealed bid at or above LowerPrice, ties going to the earliest commitment.
+// Losing bidders get their deposit back, the winner gets back what its deposit held above its bid,
+// and unrevealed deposits are forfeited to the seller.
+func (sealedBidAuction) settle(ctx contractapi.TransactionContextInterface, bid *NFTBid, seller string) (*settlement, error) {
+	sealedBids, err := getSealedBids(ctx, bid.TokenID)
+	if err != nil {
+		return nil, err
+	}
+	var winner *SealedBid
+	for _, sealed := range sealedBids {
+		if !sealed.Revealed || sealed.Amount < bid.LowerPrice || sealed.Amount > sealed.Deposit {
+			continue
+		}
+		if winner == nil || sealed.Amount > winner.Amount ||
+			(sealed.Amount == winner.Amount && sealed.CommitTime < winner.CommitTime) {
+			winner = sealed
+		}
+	}
+
+	result := &settlement{Winner: NonBidder}
+	for _, sealed := range sealedBids {
+		refund := sealed.Deposit
+		if !sealed.Revealed {
+			result.Forfeited += sealed.Deposit
+			refund = 0
+		} else if sealed == winner {
+			result.Winner = sealed.Bidder
+			result.Price = sealed.Amount
+			refund = sealed.Deposit - sealed.Amount
+		}
+		if refund > 0 {
+			_, err = credit(ctx, sealed.Bidder, refund)
+			if err != nil {
+				return nil, fmt.Errorf("failed to refund deposit: %v\n", err)
+			}
+		}
+		err = deleteSealedBid(ctx, sealed)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (sealedBidAuction) cancel(ctx contractapi.TransactionContextInterface, bid *NFTBid, currentTime uint64) (string, error) {
+	if sealedPhase(bid, currentTime) != PhaseCommit {
+		return "", fmt.Errorf("sealed-bid auction can only be cancelled in commit phase\n")
+	}
+	sealedBids, err := getSealedBids(ctx, bid.TokenID)
+	if err != nil {
+		return "", err
+	}
+	for _, sealed := range sealedBids {
+		_, err = credit(ctx, sealed.Bidder, sealed.Deposit)
+		if err != nil {
+			return "", fmt.Errorf("failed to refund deposit: %v\n", err)
+		}
+		err = deleteSealedBid(ctx, sealed)
+		if err != nil {
+			return "", err
+		}
+	}
+	return "cancelled by seller, deposits refunded", nil
+}
+
+func sealedPhase(bid *NFTBid, currentTime uint64) string {
+	if currentTime <= bid.CommitDeadline {
+		return PhaseCommit
+	}
+	if currentTime <= bid.RevealDeadline {
+		return PhaseReveal
+	}
+	return PhaseEnded
+}
+
+func getSealedAuction(ctx contractapi.TransactionContextInterface, tokenID string) (*NFTBid, error) {
+	bid, err := getBid(ctx, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if bid.AuctionType != AuctionSealedBid {
+		return nil, fmt.Errorf("not a sealed-bid auction\n")
+	}
+	return bid, nil
+}
+
+func getSealedBidTransient(ctx contractapi.TransactionContextInterface) (uint64, string, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get transient: %v\n", err)
+	}
+	amountValue, ok := transient[SealedAmountTransientKey]
+	if !ok {
+		return 0, "", fmt.Errorf("%s not in transient map\n", SealedAmountTransientKey)
+	}
+	amount, err := strconv.ParseUint(string(amountValue), 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid %s in transient map: %v\n", SealedAmountTransientKey, err)
+	}
+	salt, ok := transient[SealedSaltTransientKey]
+	if !ok || len(salt) == 0 {
+		return 0, "", fmt.Errorf("%s not in transient map\n", SealedSaltTransientKey)
+	}
+	return amount, string(salt), nil
+}
+
+func getSealedBid(ctx contractapi.TransactionContextInterface, tokenID string, bidder string) (*SealedBid, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(SealedBidPrefix, []string{tokenID, bidder})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to getstate for key: %s, %v", key, err)
+	}
+	if len(jvalue) == 0 {
+		return nil, nil
+	}
+	value := &SealedBid{}
+	err = json.Unmarshal(jvalue, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data %v", err)
+	}
+	return value, nil
+}
+
+func getSealedBids(ctx contractapi.TransactionContextInterface, tokenID string) ([]*SealedBid, error) {
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey(SealedBidPrefix, []string{tokenID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetStateByPartialCompositeKey for sealed bids: %v\n", err)
+	}
+	defer iter.Close()
+
+	var sealedBids []*SealedBid
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate sealed bids: %v\n", err)
+		}
+		value := &SealedBid{}
+		err = json.Unmarshal(kv.Value, value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data %v", err)
+		}
+		sealedBids = append(sealedBids, value)
+	}
+	return sealedBids, nil
+}
+
+func putSealedBid(ctx contractapi.TransactionContextInterface, sealed *SealedBid) error {
+	key, err := ctx.GetStub().CreateCompositeKey(SealedBidPrefix, []string{sealed.TokenID, sealed.Bidder})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	jvalue, err := json.Marshal(sealed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data %v", err)
+	}
+	return ctx.GetStub().PutState(key, jvalue)
+}
+
+func deleteSealedBid(ctx contractapi.TransactionContextInterface, sealed *SealedBid) error {
+	key, err := ctx.GetStub().CreateCompositeKey(SealedBidPrefix, []string{sealed.TokenID, sealed.Bidder})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key %v\n", err)
+	}
+	return ctx.GetStub().DelState(key)
+}
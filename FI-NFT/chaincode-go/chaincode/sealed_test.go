@@ -0,0 +1,95 @@
+package chaincode
+
+import (
+	"fmt"
+	"testing"
+)
+
+// setSealedBid passes amount and salt in the transient map of the next transactions.
+func (l *testLedger) setSealedBid(amount uint64, salt string) {
+	l.transient = map[string][]byte{
+		SealedAmountTransientKey: []byte(fmt.Sprint(amount)),
+		SealedSaltTransientKey:   []byte(salt),
+	}
+}
+
+func TestSealedBidAuction(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice", "bob", "carol")
+	l.seedNFT("1", "seller")
+	bid, err := s.AddAuction(l.ctx("seller"), "1", AuctionSealedBid, 50, 0, 10)
+	mustSucceed(t, err)
+	mustFail(t, s.Offer(l.ctx("alice"), 100, "1"), "takes bids through CommitBid")
+
+	l.setSealedBid(100, "s1")
+	mustFail(t, s.CommitBid(l.ctx("seller"), "1", SealedBidHash("1", "seller", 100, "s1"), 100), "owner cannot bid")
+	mustFail(t, s.CommitBid(l.ctx("alice"), "1", SealedBidHash("1", "alice", 100, "other"), 200), "hash does not match")
+	mustFail(t, s.CommitBid(l.ctx("alice"), "1", SealedBidHash("1", "alice", 100, "s1"), 99), "deposit does not cover the bid")
+	// alice deposits more than she bids, so that the deposit does not give the bid away
+	mustSucceed(t, s.CommitBid(l.ctx("alice"), "1", SealedBidHash("1", "alice", 100, "s1"), 200))
+	mustFail(t, s.CommitBid(l.ctx("alice"), "1", SealedBidHash("1", "alice", 100, "s1"), 200), "already committed")
+	l.setSealedBid(150, "s2")
+	mustSucceed(t, s.CommitBid(l.ctx("bob"), "1", SealedBidHash("1", "bob", 150, "s2"), 150))
+	l.setSealedBid(300, "s3")
+	mustSucceed(t, s.CommitBid(l.ctx("carol"), "1", SealedBidHash("1", "carol", 300, "s3"), 300))
+	l.expectBalance("alice", 800)
+
+	l.setSealedBid(100, "s1")
+	_, err = s.RevealBid(l.ctx("alice"), "1")
+	mustFail(t, err, "not in reveal phase")
+	l.advance(11)
+	phase, err := s.GetAuctionPhase(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if phase != PhaseReveal {
+		t.Fatalf("phase is %s", phase)
+	}
+	mustFail(t, s.CancelAuction(l.ctx("seller"), "1"), "only be cancelled in commit phase")
+	_, err = s.RevealBid(l.ctx("alice"), "1")
+	mustSucceed(t, err)
+	l.setSealedBid(150, "s2")
+	_, err = s.RevealBid(l.ctx("bob"), "1")
+	mustSucceed(t, err)
+
+	// the auction ends with the reveal phase, not with the commit phase
+	result, err := s.CanBidEnd(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if result.CanEnd || result.EndTime != bid.RevealDeadline {
+		t.Fatalf("CanBidEnd is %+v, reveal deadline %d", result, bid.RevealDeadline)
+	}
+	l.advance(SEALED_REVEAL_LIFETIME)
+	result, err = s.CanBidEnd(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if !result.CanEnd {
+		t.Fatalf("CanBidEnd is %+v after the reveal deadline", result)
+	}
+	mustSucceed(t, s.TryEndBid(l.ctx("reader"), "1"))
+
+	// bob wins with the highest revealed bid, carol never revealed and forfeits her deposit
+	if owner := l.owner("1"); owner != "bob" {
+		t.Fatalf("owner is %s", owner)
+	}
+	l.expectBalance("alice", 1000)
+	l.expectBalance("bob", 850)
+	l.expectBalance("carol", 700)
+	l.expectBalance("seller", 1450)
+}
+
+func TestCancelSealedBidAuctionRefundsDeposits(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(SmartContract)
+	l.openAccounts(1000, "seller", "alice")
+	l.seedNFT("1", "seller")
+	_, err := s.AddAuction(l.ctx("seller"), "1", AuctionSealedBid, 10, 0, 10)
+	mustSucceed(t, err)
+	l.setSealedBid(20, "salt")
+	mustSucceed(t, s.CommitBid(l.ctx("alice"), "1", SealedBidHash("1", "alice", 20, "salt"), 50))
+	l.expectBalance("alice", 950)
+	mustSucceed(t, s.CancelAuction(l.ctx("seller"), "1"))
+	l.expectBalance("alice", 1000)
+	sealed, err := getSealedBids(l.ctx("reader"), "1")
+	mustSucceed(t, err)
+	if len(sealed) != 0 {
+		t.Fatalf("%d sealed bids left", len(sealed))
+	}
+}
@@ -5,6 +5,8 @@ import "fi-nft/chaincode"
 
 func main() {
 	smartContract := new(chaincode.SmartContract)
+	smartContract.TransactionContextHandler = new(chaincode.TransactionContext)
+	smartContract.AfterTransaction = chaincode.EmitEvents
 
 	cc, err := contractapi.NewChaincode(smartContract)
 
@@ -48,7 +48,7 @@ async function Login(clientID){
 }
 
 
-//use org1.admin as operator to registe an account (uploadAccountBalance with 100)
+//registe an account for clientID, then use org1.admin as operator to give it 100
 async function Register(clientID){
     try{
         // registe wallet
@@ -68,9 +68,11 @@ async function Register(clientID){
         const network2 = await gateway2.getNetwork(channelName)
         const contract2 = network2.getContract(chaincodeName);
         let account=await contract2.evaluateTransaction('ClientAccountID')  // get account for clientID
+        // fails if the account exists, so a retried registration never funds it twice
+        await contract2.submitTransaction('RegisterAccount')
         gateway2.disconnect()
 
-        // use admin account, put account~balance(100) to smart contract
+        // use admin account, adjust account~balance by 100
         let ccp;
         let walletPath;
         ccp=buildCCPOrg1()
@@ -85,7 +87,7 @@ async function Register(clientID){
         const network = await gateway.getNetwork(channelName)
         const contract = network.getContract(chaincodeName);
 
-        await contract.submitTransaction('InitAccountBalance',account.toString(),'100')
+        await contract.submitTransaction('AdjustBalance',account.toString(),'100','registration')
         gateway.disconnect()
     }catch(err){
         console.error(`******** FAILED to Register: ${err}`)
@@ -124,7 +126,7 @@ async function GetAccountBalance(clientID,org){
     }
 }
 
-async function Mint(clientID, org, tokenID, ftype){
+async function Mint(clientID, org, tokenID, ftype, royaltyBasisPoints='0'){
     try{
         let ccp;
         let walletPath;
@@ -155,7 +157,8 @@ async function Mint(clientID, org, tokenID, ftype){
         const hash = await Hash.of(data)
         console.log('got file cid: '+hash)
 
-        let result = await contract.submitTransaction('MintWithFile',tokenID,ftype,hash)
+        //the chaincode only records the cid, it does not call IPFS
+        let result = await contract.submitTransaction('MintWithCID',tokenID,hash,ftype,data.length.toString(),royaltyBasisPoints)
 
         gateway.disconnect()
         return result
@@ -326,15 +329,14 @@ async function TotalBids(clientID,org){
         const network = await gateway.getNetwork(channelName)
         const contract = network.getContract(chaincodeName);
 
-        const currentTime=new Date().getTime()
         let totalBids
-        await contract.evaluateTransaction('TotalBidsWithTimeOutCheck',currentTime.toString()).then(async (result) => {
+        await contract.evaluateTransaction('TotalBidsWithTimeOutCheck').then(async (result) => {
             console.log(prettyJSONString(result))
             const json_result = JSON.parse(result.toString())
             totalBids = json_result.TotalAliveBid
             const hasTimeOut = json_result.HasTimeOutBid
             if (hasTimeOut.toString() === 'true') {
-                await contract.submitTransaction('FindBidToEnd', currentTime.toString())
+                await contract.submitTransaction('FindBidToEnd')
             }
         } )
         // let i = parseInt(result.toString())
@@ -370,8 +372,7 @@ async function Offer(clientID, org, tokenID, Price){
         console.log(Price+tokenID)
 
         await contract.submitTransaction('Offer',Price,tokenID).then(async () => {
-            const currentTime = new Date().getTime()
-            await contract.submitTransaction('TryEndBid', tokenID, currentTime.toString())
+            await contract.submitTransaction('TryEndBid', tokenID)
         })
 
     }catch (err) {
@@ -508,7 +509,9 @@ async function GetNFTByIndex(clientID,org, index){
     }
 }
 
-async function AddBid(clientID,org,tokenID, lowPrice,upPrice,lifetime){
+// reservePrice and reserveSalt are sent as transient data and only their hash reaches the ledger,
+// keep the salt to reveal the reserve price with RevealReserve once the auction has ended
+async function AddBid(clientID,org,tokenID, lowPrice,upPrice,lifetime,minIncrement='0',minIncrementBasisPoints='0',reservePrice='0',reserveSalt=''){
     try{
         let ccp;
         let walletPath;
@@ -531,8 +534,14 @@ async function AddBid(clientID,org,tokenID, lowPrice,upPrice,lifetime){
 
         const network = await gateway.getNetwork(channelName)
         const contract = network.getContract(chaincodeName);
-        const currentTime=new Date().getTime().toString()
-        let result = await contract.submitTransaction('AddBid',tokenID,lowPrice,upPrice,currentTime,lifetime)
+        const tx = contract.createTransaction('AddBid')
+        if (reservePrice !== '0'){
+            if (reserveSalt === ''){
+                throw new Error('a salt is needed to hide the reserve price')
+            }
+            tx.setTransient({reservePrice: Buffer.from(reservePrice), salt: Buffer.from(reserveSalt)})
+        }
+        let result = await tx.submit(tokenID,lowPrice,upPrice,lifetime,minIncrement,minIncrementBasisPoints)
         return result
     }catch (err) {
         console.error(`******** FAILED to add bid: ${err}`)
@@ -540,6 +549,38 @@ async function AddBid(clientID,org,tokenID, lowPrice,upPrice,lifetime){
     }
 }
 
+async function RevealReserve(clientID,org,tokenID,reservePrice,reserveSalt){
+    try{
+        let ccp;
+        let walletPath;
+        if (org==='org1'){
+            ccp=buildCCPOrg1()
+            walletPath=path.join(__dirname, 'wallet/org1');
+        }else{
+            ccp=buildCCPOrg2()
+            walletPath=path.join(__dirname, 'wallet/org2');
+        }
+        const wallet = await buildWallet(Wallets, walletPath);
+
+        const gateway = new Gateway();
+        await gateway.connect(ccp, {
+            wallet: wallet,
+            identity: clientID,
+            discovery: { enabled: true, asLocalhost: true } // using asLocalhost as this gateway is using a fabric network deployed locally
+        });
+
+        const network = await gateway.getNetwork(channelName)
+        const contract = network.getContract(chaincodeName);
+        let result = await contract.createTransaction('RevealReserve')
+            .setTransient({reservePrice: Buffer.from(reservePrice), salt: Buffer.from(reserveSalt)})
+            .submit(tokenID)
+        return result
+    }catch (err) {
+        console.error(`******** FAILED to reveal reserve price: ${err}`)
+        throw err
+    }
+}
+
 async function IsOnSale(clientID,org, tokenID){
     try{
         let ccp;
@@ -602,4 +643,4 @@ async function fullflowtest(){
 
 // testRequestBids()
 
-module.exports={Mint,Request,ClientAccountID,Transfer,TotalBids,GetBidsByIndex,Register,Login,GetAccountBalance,TotalNFTs,GetNFTByIndex,IsOnSale, IsNFTExist, AddBid, Offer}
+module.exports={Mint,Request,ClientAccountID,Transfer,TotalBids,GetBidsByIndex,Register,Login,GetAccountBalance,TotalNFTs,GetNFTByIndex,IsOnSale, IsNFTExist, AddBid, RevealReserve, Offer}